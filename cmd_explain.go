@@ -0,0 +1,61 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+// runExplain implements the `explain` subcommand.
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	must.Succeed(fs.Parse(args))
+	if fs.NArg() > 1 {
+		logg.Fatal("unexpected positional arguments: %s", strings.Join(fs.Args()[1:], " "))
+	}
+	query := fs.Arg(0)
+
+	sink := core.NewExplainSink()
+	cfg, sr := prepare(".", sink)
+	render(sink, &cfg, sr)
+
+	explanations := sink.Query(query)
+	if len(explanations) == 0 {
+		logg.Fatal("nothing matches %q, run `go-makefile-maker explain` without arguments to list everything", query)
+	}
+	printExplanations(os.Stdout, explanations)
+}
+
+func printExplanations(w io.Writer, explanations []core.Explanation) {
+	for _, e := range explanations {
+		fmt.Fprintln(w, e.Subject)
+		for _, reason := range e.Reasons {
+			fmt.Fprintf(w, "  - %s\n", reason)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+func TestExplain(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "application")
+	sink := core.NewExplainSink()
+	cfg, sr := prepare(fixtureDir, sink)
+	render(sink, &cfg, sr)
+
+	testCases := map[string]string{
+		"license-headers": `
+Makefile:license-headers
+  - the module path "github.com/sapcc/example-app" belongs to an SAP organization (github.com/sapcc, github.wdf.sap.corp or github.tools.sap)
+`,
+		"codeql": `
+.github/workflows/codeql.yaml
+  - githubWorkflow.securityChecks.enabled is set
+`,
+	}
+	for query, expected := range testCases {
+		var buf strings.Builder
+		printExplanations(&buf, sink.Query(query))
+		if actual := buf.String(); actual != strings.TrimPrefix(expected, "\n") {
+			t.Errorf("expected explanation for %q to be:\n%s\nbut got:\n%s", query, strings.TrimPrefix(expected, "\n"), actual)
+		}
+	}
+}
+
+func TestExplainCrossCompile(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "multibinary")
+	sink := core.NewExplainSink()
+	cfg, sr := prepare(fixtureDir, sink)
+	// without an explicit CGO_ENABLED, the cgo default of the repository must not leak into cross builds
+	enableCGO := true
+	cfg.Golang.EnableCGO = &enableCGO
+	cfg.Binaries[0].Env = nil
+	render(sink, &cfg, sr)
+
+	testCases := map[string]string{
+		"build-cross": `
+Makefile:build-cross
+  - crossCompile contains linux/arm64, windows/amd64
+  - bar-daemon is left out because it is only built for its own goos/goarch
+`,
+		"build/linux_arm64/bar": `
+Makefile:build/linux_arm64/bar
+  - crossCompile contains linux/arm64, and binaries contains an entry for bar
+  - cgo is disabled because it would require a C toolchain for the target platform, unless the binary sets CGO_ENABLED=1 in its env
+`,
+	}
+	for query, expected := range testCases {
+		var buf strings.Builder
+		printExplanations(&buf, sink.Query(query))
+		if actual := buf.String(); actual != strings.TrimPrefix(expected, "\n") {
+			t.Errorf("expected explanation for %q to be:\n%s\nbut got:\n%s", query, strings.TrimPrefix(expected, "\n"), actual)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+var initConfigTmpl = template.Must(template.New("init").Parse(strings.TrimSpace(`
+# Configuration file for <https://github.com/sapcc/go-makefile-maker>
+# yaml-language-server: $schema={{ .SchemaURL }}
+#
+# This file was created by "go-makefile-maker init". Please review it, then
+# run "go-makefile-maker" to generate the Makefile and the other files.
+
+metadata:
+{{- if .URL }}
+  url: {{ .URL }}
+{{- else }}
+  url: "" # TODO: could not be determined from the "origin" git remote, please fill in
+{{- end }}
+{{- if .Binaries }}
+
+# One "build/<name>" target is generated for each binary. Binaries with
+# "installTo" are installed by "make install".
+binaries:
+{{- range .Binaries }}
+  - name:        {{ .Name }}
+    fromPackage: {{ .FromPackage }}
+    installTo:   {{ .InstallTo }}
+{{- end }}
+{{- end }}
+
+golang:
+  enableVendoring: {{ .EnableVendoring }}
+  setGoModVersion: true
+
+golangciLint:
+  createConfig: true
+{{- if .EnableRelease }}
+
+goReleaser:
+  createConfig: true
+{{- end }}
+{{- if .Binaries }}
+
+# Uncomment to generate a Dockerfile that runs "make install".
+# dockerfile:
+#   enabled: true
+{{- end }}
+
+githubWorkflow:
+  ci:
+    enabled: true
+{{- if .IsGitHubCom }}
+    coveralls: true
+{{- end }}
+{{- if .UsesPostgres }}
+    postgres:
+      enabled: true
+{{- end }}
+{{- if .HasLicense }}
+  license:
+    enabled: true
+{{- end }}
+{{- if .EnableRelease }}
+  release:
+    enabled: true
+{{- end }}
+  securityChecks:
+    enabled: true
+  spellCheck:
+    enabled: true
+
+renovate:
+  enabled: true
+`)))
+
+type initConfigTmplData struct {
+	SchemaURL       string
+	URL             string
+	IsGitHubCom     bool
+	Binaries        []core.BinaryConfiguration
+	EnableVendoring bool
+	EnableRelease   bool
+	HasLicense      bool
+	UsesPostgres    bool
+}
+
+// runInit implements the `init` subcommand.
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite an existing "+core.ConfigFilename+".")
+	must.Succeed(fs.Parse(args))
+	if fs.NArg() > 0 {
+		logg.Fatal("unexpected positional arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if _, err := os.Stat(core.ConfigFilename); err == nil && !*force {
+		logg.Fatal("%s already exists, use --force to overwrite it", core.ConfigFilename)
+	}
+
+	repoURL, err := core.GitRepo{Dir: "."}.RemoteURL("origin")
+	if err != nil {
+		logg.Error(err.Error())
+	}
+
+	buf := renderInitConfig(".", repoURL)
+	must.Succeed(core.DiskSink{}.WriteFile(core.ConfigFilename, buf, 0o666))
+	logg.Info("wrote %s, please review it and then run go-makefile-maker", core.ConfigFilename)
+}
+
+// renderInitConfig builds a starter config for the repository in the given
+// directory, based on what can be found in it.
+func renderInitConfig(root, repoURL string) []byte {
+	sr := core.Scan(root, false)
+	data := initConfigTmplData{
+		SchemaURL:       core.SchemaURL,
+		URL:             repoURL,
+		IsGitHubCom:     strings.HasPrefix(repoURL, "https://github.com/"),
+		EnableVendoring: isDir(filepath.Join(root, "vendor")),
+		HasLicense:      len(must.Return(filepath.Glob(filepath.Join(root, "LICENSE*")))) > 0,
+		UsesPostgres:    sr.UsesTestService("postgres"),
+	}
+
+	// We only consider binaries in the conventional locations here, other main
+	// packages are usually test helpers or code generators.
+	for _, pkg := range must.Return(core.FindMainPackages(root, sr.Modules)) {
+		name := path.Base(pkg)
+		switch {
+		case pkg == ".":
+			name = path.Base(sr.MustModulePath())
+		case !strings.HasPrefix(pkg, "./cmd/"):
+			continue
+		}
+		data.Binaries = append(data.Binaries, core.BinaryConfiguration{
+			Name:        name,
+			FromPackage: pkg,
+			InstallTo:   "bin/",
+		})
+	}
+
+	// The release workflow uses the changelog for the release notes.
+	_, err := os.Stat(filepath.Join(root, "CHANGELOG.md"))
+	data.EnableRelease = err == nil && len(data.Binaries) > 0
+
+	var buf bytes.Buffer
+	must.Succeed(initConfigTmpl.Execute(&buf, data))
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
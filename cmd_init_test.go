@@ -0,0 +1,74 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+func TestInitConfigIsValid(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module github.com/example/thing\n\ngo 1.21\n\nrequire github.com/lib/pq v1.10.9\n",
+		"main.go":             "package main\n\nfunc main() {}\n",
+		"cmd/helper/main.go":  "package main\n\nfunc main() {}\n",
+		"tools/gen/main.go":   "package main\n\nfunc main() {}\n",
+		"internal/lib/lib.go": "package lib\n",
+		"LICENSE":             "",
+		"CHANGELOG.md":        "",
+	}
+	for path, contents := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		err := os.MkdirAll(filepath.Dir(fullPath), 0o755)
+		if err == nil {
+			err = os.WriteFile(fullPath, []byte(contents), 0o666)
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	buf := renderInitConfig(root, "https://github.com/example/thing")
+	cfg, issues := core.ParseConfiguration(buf)
+	for _, issue := range issues {
+		// this check depends on the git repo in the working directory, not on the generated config
+		if issue.Path != "githubWorkflow.global.defaultBranch" {
+			t.Error(issue.Format(core.ConfigFilename))
+		}
+	}
+
+	var binaries []string
+	for _, b := range cfg.Binaries {
+		binaries = append(binaries, b.Name+"="+b.FromPackage)
+	}
+	if actual, expected := strings.Join(binaries, ","), "thing=.,helper=./cmd/helper"; actual != expected {
+		t.Errorf("expected binaries %q, but got %q", expected, actual)
+	}
+	if cfg.GitHubWorkflow == nil || !cfg.GitHubWorkflow.CI.Postgres.Enabled || !cfg.GitHubWorkflow.Release.Enabled {
+		t.Errorf("expected postgres and release workflow to be enabled, but got %#v", cfg.GitHubWorkflow)
+	}
+	if cfg.Golang.EnableVendoring {
+		t.Error("expected vendoring to be disabled")
+	}
+}
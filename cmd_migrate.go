@@ -0,0 +1,68 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+	"github.com/sapcc/go-makefile-maker/internal/diff"
+)
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Do not write any files. Instead, show a diff for every file that would be changed.")
+	must.Succeed(fs.Parse(args))
+
+	// base files referenced via `extends` are not migrated automatically
+	// because they are often shared between repositories, but they can be
+	// given explicitly
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{core.ConfigFilename}
+	}
+
+	for _, path := range paths {
+		buf := must.Return(os.ReadFile(path))
+		result, changes, err := core.MigrateConfiguration(buf)
+		if err != nil {
+			logg.Fatal("cannot parse %s: %s", path, err.Error())
+		}
+		for _, change := range changes {
+			logg.Info("%s: %s", path, change)
+		}
+		if bytes.Equal(buf, result) {
+			logg.Info("%s: nothing to migrate", path)
+			continue
+		}
+
+		if *dryRun {
+			fmt.Print(diff.Unified("a/"+path, "b/"+path, string(buf), string(result)))
+		} else {
+			must.Succeed(core.DiskSink{}.WriteFile(path, result, 0o666))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+// runPinActions implements the `pin-actions` subcommand.
+func runPinActions(args []string) {
+	fs := flag.NewFlagSet("pin-actions", flag.ExitOnError)
+	must.Succeed(fs.Parse(args))
+	if fs.NArg() > 0 {
+		logg.Fatal("unexpected positional arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	sink := core.NewMemorySink()
+	cfg, sr := prepare(".", sink)
+	if cfg.GitHubWorkflow == nil {
+		logg.Fatal("there is nothing to pin because githubWorkflow is not configured")
+	}
+
+	// render the workflows without pinning to find out which actions they use
+	cfg.GitHubWorkflow.PinActions.Enabled = false
+	render(sink, &cfg, sr)
+
+	lock := core.ActionLock{Actions: make(map[string]core.ActionPin)}
+	for _, action := range usedActions(sink) {
+		if _, _, ok := lock.Pin(action); ok {
+			continue // e.g. github/codeql-action/analyze has the same commit as github/codeql-action/init
+		}
+		pin, err := core.ResolveAction(action)
+		if err != nil {
+			logg.Fatal(err.Error())
+		}
+		lock.AddAction(action, pin)
+		logg.Info("pinned %s to %s (%s)", action, pin.Commit, pin.Version)
+	}
+
+	buf := must.Return(lock.Bytes())
+	must.Succeed(core.DiskSink{}.WriteFile(core.ActionLockFilename, buf, 0o666))
+	logg.Info("wrote %s, run go-makefile-maker to update the workflows", core.ActionLockFilename)
+}
+
+var usesRx = regexp.MustCompile(`(?m)^\s*(?:- )?uses: (\S+)$`)
+
+// usedActions returns all actions referenced in the workflows in the given sink.
+func usedActions(sink *core.MemorySink) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, f := range sink.Files() {
+		if f.Deleted || !strings.HasPrefix(f.Path, ".github/workflows/") {
+			continue
+		}
+		for _, match := range usesRx.FindAllStringSubmatch(string(f.Contents), -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				result = append(result, match[1])
+			}
+		}
+	}
+	return result
+}
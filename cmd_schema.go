@@ -0,0 +1,52 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+// schemaFilename is where the output of `go-makefile-maker schema` is
+// committed in this repository, so that editors can fetch it from core.SchemaURL.
+const schemaFilename = "Makefile.maker.schema.json"
+
+// printSchema implements the `schema` subcommand.
+func printSchema(args []string) {
+	if len(args) > 0 {
+		logg.Fatal("unexpected positional arguments: %s", strings.Join(args, " "))
+	}
+	must.Return(os.Stdout.Write(renderSchema()))
+}
+
+func renderSchema() []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // in order to preserve `<` in descriptions
+	must.Succeed(encoder.Encode(core.ConfigurationSchema()))
+	return buf.Bytes()
+}
@@ -0,0 +1,178 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// ActionLockFilename is the file in which the commit SHAs of GitHub actions
+// are recorded when `githubWorkflow.pinActions` is enabled. It is written by
+// `go-makefile-maker pin-actions` and read during generation without any
+// network access.
+const ActionLockFilename = "Makefile.maker.lock.json"
+
+// ActionLock is the contents of the action lock file.
+type ActionLock struct {
+	// Actions maps action references like "actions/checkout@v4" (without any
+	// subpath like in "github/codeql-action/init@v2") to the commit that they
+	// were resolved to.
+	Actions map[string]ActionPin `json:"actions"`
+}
+
+// ActionPin appears in type ActionLock.
+type ActionPin struct {
+	Commit string `json:"commit"`
+	// the most specific tag that points to the commit, e.g. "v4.1.1" for "v4"
+	Version string `json:"version"`
+}
+
+// ReadActionLock reads the action lock file in the given repository root. If
+// there is none, an empty lock is returned.
+func ReadActionLock(root string) (ActionLock, error) {
+	result := ActionLock{Actions: make(map[string]ActionPin)}
+	buf, err := os.ReadFile(filepath.Join(root, ActionLockFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(buf, &result)
+	if err != nil {
+		return result, fmt.Errorf("cannot parse %s: %w", ActionLockFilename, err)
+	}
+	if result.Actions == nil {
+		result.Actions = make(map[string]ActionPin)
+	}
+	return result, nil
+}
+
+// Bytes returns the serialization of the action lock file.
+func (l ActionLock) Bytes() ([]byte, error) {
+	buf, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+// actionLockKey returns the key for the given action reference in ActionLock.Actions.
+func actionLockKey(action string) string {
+	repo, ref := splitAction(action)
+	return repo + "@" + ref
+}
+
+// Pin returns the given action reference (e.g. "github/codeql-action/init@v2")
+// with the ref replaced by the commit from the lock file. The second return
+// value is the version that the commit corresponds to. If the action is not
+// in the lock file, ok is false.
+func (l ActionLock) Pin(action string) (pinned, version string, ok bool) {
+	pin, exists := l.Actions[actionLockKey(action)]
+	if !exists {
+		return action, "", false
+	}
+	name, _, _ := strings.Cut(action, "@")
+	return name + "@" + pin.Commit, pin.Version, true
+}
+
+// ResolveAction looks up the commit that the given action reference points
+// to, by running `git ls-remote` on the action's repository on GitHub. The
+// result can be recorded with ActionLock.AddAction().
+func ResolveAction(action string) (ActionPin, error) {
+	repo, ref := splitAction(action)
+	out, err := exec.Command("git", "ls-remote", "--tags", "--heads", "https://github.com/"+repo).Output()
+	if err != nil {
+		return ActionPin{}, fmt.Errorf("could not list refs of %s: %w", repo, err)
+	}
+	return parseLsRemote(string(out), repo, ref)
+}
+
+// AddAction records the given pin for the given action reference.
+func (l ActionLock) AddAction(action string, pin ActionPin) {
+	l.Actions[actionLockKey(action)] = pin
+}
+
+var commitRx = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// parseLsRemote finds the commit for the given ref in the output of `git ls-remote`.
+func parseLsRemote(output, repo, ref string) (ActionPin, error) {
+	// collect the commit for each tag and branch (for annotated tags, the
+	// commit is on the line with the "^{}" suffix)
+	tags := make(map[string]string)
+	branches := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		commit, refName, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if tag, ok := strings.CutPrefix(refName, "refs/tags/"); ok {
+			tag, isPeeled := strings.CutSuffix(tag, "^{}")
+			if _, exists := tags[tag]; !exists || isPeeled {
+				tags[tag] = commit
+			}
+		} else if branch, ok := strings.CutPrefix(refName, "refs/heads/"); ok {
+			branches[branch] = commit
+		}
+	}
+
+	var pin ActionPin
+	switch {
+	case tags[ref] != "":
+		pin.Commit = tags[ref]
+	case branches[ref] != "":
+		pin.Commit = branches[ref]
+	case commitRx.MatchString(ref):
+		pin.Commit = ref
+	default:
+		return ActionPin{}, fmt.Errorf("%s does not have a tag or branch named %q", repo, ref)
+	}
+
+	// find the most specific version tag for the comment, e.g. "v4.1.1" for "v4"
+	pin.Version = ref
+	for tag, commit := range tags {
+		if commit != pin.Commit || !semver.IsValid(tag) {
+			continue
+		}
+		if !semver.IsValid(pin.Version) || isMoreSpecificVersion(tag, pin.Version) {
+			pin.Version = tag
+		}
+	}
+	return pin, nil
+}
+
+// isMoreSpecificVersion returns whether tag "a" is more specific than tag
+// "b", e.g. "v4.1.1" is more specific than "v4.1" or "v4".
+func isMoreSpecificVersion(a, b string) bool {
+	aParts, bParts := strings.Count(a, "."), strings.Count(b, ".")
+	if aParts != bParts {
+		return aParts > bParts
+	}
+	return semver.Compare(a, b) > 0
+}
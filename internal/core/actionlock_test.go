@@ -0,0 +1,79 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"testing"
+)
+
+// abbreviated output of `git ls-remote --tags --heads https://github.com/github/codeql-action`
+const lsRemoteOutput = `
+1111111111111111111111111111111111111111	refs/heads/main
+2222222222222222222222222222222222222222	refs/tags/v2
+3333333333333333333333333333333333333333	refs/tags/v2.22.3
+4444444444444444444444444444444444444444	refs/tags/v2.22.4
+2222222222222222222222222222222222222222	refs/tags/v2.22.5
+5555555555555555555555555555555555555555	refs/tags/codeql-bundle-20231020
+aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa	refs/tags/v2.22
+2222222222222222222222222222222222222222	refs/tags/v2.22^{}
+`
+
+func TestParseLsRemote(t *testing.T) {
+	testCases := map[string]ActionPin{
+		// for annotated tags, the peeled commit must be used
+		"v2.22": {Commit: "2222222222222222222222222222222222222222", Version: "v2.22.5"},
+		// major version tags are resolved to the most specific version tag
+		"v2":      {Commit: "2222222222222222222222222222222222222222", Version: "v2.22.5"},
+		"v2.22.3": {Commit: "3333333333333333333333333333333333333333", Version: "v2.22.3"},
+		"main":    {Commit: "1111111111111111111111111111111111111111", Version: "main"},
+		"4444444444444444444444444444444444444444": {Commit: "4444444444444444444444444444444444444444", Version: "v2.22.4"},
+	}
+	for ref, expected := range testCases {
+		actual, err := parseLsRemote(lsRemoteOutput, "github/codeql-action", ref)
+		if err != nil {
+			t.Errorf("unexpected error for ref %q: %s", ref, err.Error())
+			continue
+		}
+		if actual != expected {
+			t.Errorf("expected %#v for ref %q, but got %#v", expected, ref, actual)
+		}
+	}
+
+	_, err := parseLsRemote(lsRemoteOutput, "github/codeql-action", "v3")
+	expectedError := `github/codeql-action does not have a tag or branch named "v3"`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error %q, but got %v", expectedError, err)
+	}
+}
+
+func TestActionLockPin(t *testing.T) {
+	lock := ActionLock{Actions: make(map[string]ActionPin)}
+	lock.AddAction("github/codeql-action/init@v2", ActionPin{Commit: "2222222222222222222222222222222222222222", Version: "v2.22.5"})
+
+	// subpaths of the same action share the same entry
+	pinned, version, ok := lock.Pin("github/codeql-action/analyze@v2")
+	if !ok || pinned != "github/codeql-action/analyze@2222222222222222222222222222222222222222" || version != "v2.22.5" {
+		t.Errorf("unexpected result: %q, %q, %t", pinned, version, ok)
+	}
+
+	_, _, ok = lock.Pin("github/codeql-action/analyze@v3")
+	if ok {
+		t.Error("expected github/codeql-action/analyze@v3 to not be pinned")
+	}
+}
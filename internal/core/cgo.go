@@ -0,0 +1,108 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+)
+
+// knownCGODependencies are import paths of libraries that cannot be built
+// without cgo. Packages below these paths are matched as well. They are
+// matched against the imports in the Go files and against the module paths
+// in the require directives of all go.mod files.
+var knownCGODependencies = []string{
+	"github.com/ceph/go-ceph",
+	"github.com/confluentinc/confluent-kafka-go",
+	"github.com/mattn/go-sqlite3",
+}
+
+// detectCGO returns whether any Go file (including tests) in the given module
+// directories uses cgo, either directly through `import "C"` or by importing
+// one of the knownCGODependencies. Build constraints are not evaluated, so a
+// file that is only built on some platforms counts as well.
+func detectCGO(root string, moduleDirs []string) (bool, error) {
+	fset := token.NewFileSet()
+	for _, dir := range moduleDirs {
+		moduleRoot := filepath.Join(root, filepath.FromSlash(dir))
+		found := false
+		err := filepath.WalkDir(moduleRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path == moduleRoot {
+					return nil
+				}
+				// nested modules are checked separately if they are part of the repository's modules
+				if isIgnoredDir(d.Name()) || fileExists(filepath.Join(path, ModFilename)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// like the go command, ignore files starting with "." or "_"
+			name := d.Name()
+			if !strings.HasSuffix(name, ".go") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return nil
+			}
+
+			file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+			if err != nil {
+				// the go command will complain about this file anyway, so we should not fail on it
+				logg.Other("WARNING", "cannot check %s for cgo usage: %s", path, err.Error())
+				return nil
+			}
+			for _, spec := range file.Imports {
+				importPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					return fmt.Errorf("cannot check %s for cgo usage: %w", path, err)
+				}
+				if isCGOImport(importPath) {
+					found = true
+					return filepath.SkipAll
+				}
+			}
+			return nil
+		})
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// isCGOImport returns whether importing the given package requires cgo.
+func isCGOImport(importPath string) bool {
+	if importPath == "C" {
+		return true
+	}
+	for _, dep := range knownCGODependencies {
+		if importPath == dep || strings.HasPrefix(importPath, dep+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -19,8 +19,12 @@
 package core
 
 import (
-	"os/exec"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sapcc/go-bits/logg"
 )
@@ -35,13 +39,22 @@ var AutogeneratedHeader = strings.TrimSpace(`
 ///////////////////////////////////////////////////////////////////////////////
 // Core configuration
 
+// ConfigFilename is the name of the configuration file in the repository root.
+const ConfigFilename = "Makefile.maker.yaml"
+
 // Configuration is the data structure that we read from the input file.
 type Configuration struct {
 	Verbatim       string                       `yaml:"verbatim"`
 	VariableValues map[string]string            `yaml:"variables"`
 	Binaries       []BinaryConfiguration        `yaml:"binaries"`
+	AutoBinaries   AutoBinariesConfiguration    `yaml:"autoBinaries"`
+	CrossCompile   []string                     `yaml:"crossCompile"`
 	Test           TestConfiguration            `yaml:"testPackages"`
 	Coverage       CoverageConfiguration        `yaml:"coverageTest"`
+	GoTest         GoTestConfiguration          `yaml:"goTest"`
+	TestGroups     []TestGroup                  `yaml:"testGroups"`
+	Benchmarks     BenchmarksConfiguration      `yaml:"benchmarks"`
+	Fuzzing        FuzzingConfiguration         `yaml:"fuzzing"`
 	Golang         GolangConfiguration          `yaml:"golang"`
 	GolangciLint   GolangciLintConfiguration    `yaml:"golangciLint"`
 	GoReleaser     GoReleaserConfiguration      `yaml:"goReleaser"`
@@ -51,6 +64,12 @@ type Configuration struct {
 	Renovate       RenovateConfig               `yaml:"renovate"`
 	Dockerfile     DockerfileConfig             `yaml:"dockerfile"`
 	Metadata       Metadata                     `yaml:"metadata"`
+	ToolVersions   ToolVersions                 `yaml:"toolVersions"`
+	TestServices   TestServicesConfig           `yaml:"testServices"`
+
+	// This is set if the config file contains `binaries: auto`. In this case,
+	// Binaries is filled by DetectBinaries() instead of from the config file.
+	AutoDetectBinaries bool `yaml:"-"`
 }
 
 // Variable returns the value of this variable if it's overridden in the config,
@@ -68,9 +87,63 @@ func (c Configuration) Variable(name, defaultValue string) string {
 
 // BinaryConfiguration appears in type Configuration.
 type BinaryConfiguration struct {
-	Name        string `yaml:"name"`
-	FromPackage string `yaml:"fromPackage"`
-	InstallTo   string `yaml:"installTo"`
+	Name        string   `yaml:"name"`
+	FromPackage string   `yaml:"fromPackage"`
+	InstallTo   string   `yaml:"installTo"`
+	BuildFlags  string   `yaml:"buildFlags"`
+	Ldflags     string   `yaml:"ldflags"`
+	Tags        []string `yaml:"tags"`
+	Env         []string `yaml:"env"`
+	GOOS        string   `yaml:"goos"`
+	GOARCH      string   `yaml:"goarch"`
+}
+
+// HasBuildSettings returns whether any of the per-binary build settings is set.
+func (b BinaryConfiguration) HasBuildSettings() bool {
+	return b.BuildFlags != "" || b.Ldflags != "" || len(b.Tags) > 0 || len(b.Env) > 0 || b.GOOS != "" || b.GOARCH != ""
+}
+
+// BuildEnv returns the environment variables for `go build`, i.e. GOOS and
+// GOARCH (if set) followed by Env.
+func (b BinaryConfiguration) BuildEnv() []string {
+	var result []string
+	if b.GOOS != "" {
+		result = append(result, "GOOS="+b.GOOS)
+	}
+	if b.GOARCH != "" {
+		result = append(result, "GOARCH="+b.GOARCH)
+	}
+	return append(result, b.Env...)
+}
+
+// UsesCGO returns whether this binary is built with cgo. This is
+// golang.UsesCGO(), unless the binary sets CGO_ENABLED in its env.
+func (b BinaryConfiguration) UsesCGO(golang GolangConfiguration, sr ScanResult) bool {
+	if enabled, ok := b.cgoEnabledFromEnv(); ok {
+		return enabled
+	}
+	return golang.UsesCGO(sr)
+}
+
+// SetsCGOEnabled returns whether the binary sets CGO_ENABLED in its env.
+func (b BinaryConfiguration) SetsCGOEnabled() bool {
+	_, ok := b.cgoEnabledFromEnv()
+	return ok
+}
+
+func (b BinaryConfiguration) cgoEnabledFromEnv() (enabled, ok bool) {
+	for _, kv := range b.Env {
+		if value, ok := strings.CutPrefix(kv, "CGO_ENABLED="); ok {
+			return value == "1", true
+		}
+	}
+	return false, false
+}
+
+// AutoBinariesConfiguration appears in type Configuration.
+type AutoBinariesConfiguration struct {
+	Exclude   []string `yaml:"exclude"`
+	InstallTo string   `yaml:"installTo"`
 }
 
 // TestConfiguration appears in type Configuration.
@@ -85,10 +158,196 @@ type CoverageConfiguration struct {
 	Except string `yaml:"except"`
 }
 
+// GoTestConfiguration appears in type Configuration.
+type GoTestConfiguration struct {
+	Parallelism int      `yaml:"parallelism"`
+	Timeout     string   `yaml:"timeout"`
+	Count       int      `yaml:"count"`
+	Shuffle     string   `yaml:"shuffle"`
+	Race        bool     `yaml:"race"`
+	Tags        []string `yaml:"tags"`
+	FailFast    bool     `yaml:"failFast"`
+}
+
+// Flags returns the flags for `go test`, except for -race and the coverage flags.
+func (g GoTestConfiguration) Flags() string {
+	var flags []string
+	if len(g.Tags) > 0 {
+		flags = append(flags, "-tags "+strings.Join(g.Tags, ","))
+	}
+	shuffle := g.Shuffle
+	if shuffle == "" {
+		shuffle = "on"
+	}
+	flags = append(flags, "-shuffle="+shuffle)
+	parallelism := g.Parallelism
+	if parallelism == 0 {
+		parallelism = 1
+	}
+	flags = append(flags, fmt.Sprintf("-p %d", parallelism))
+	if g.Timeout != "" {
+		flags = append(flags, "-timeout "+g.Timeout)
+	}
+	if g.Count != 0 {
+		flags = append(flags, fmt.Sprintf("-count %d", g.Count))
+	}
+	if g.FailFast {
+		flags = append(flags, "-failfast")
+	}
+	return strings.Join(flags, " ")
+}
+
+// CoverMode returns the value for `go test -covermode`. The race detector
+// requires atomic counters.
+func (g GoTestConfiguration) CoverMode() string {
+	if g.Race {
+		return "atomic"
+	}
+	return "count"
+}
+
+// ForGroup returns the configuration for running the tests of the given test
+// group, i.e. with the group's tags added and its timeout taking precedence.
+func (g GoTestConfiguration) ForGroup(tg TestGroup) GoTestConfiguration {
+	g.Tags = append(slices.Clone(g.Tags), tg.Tags...)
+	if tg.Timeout != "" {
+		g.Timeout = tg.Timeout
+	}
+	return g
+}
+
+// TestGroup appears in type Configuration.
+type TestGroup struct {
+	Name        string   `yaml:"name"`
+	Only        string   `yaml:"only"`
+	Except      string   `yaml:"except"`
+	Tags        []string `yaml:"tags"`
+	Env         []string `yaml:"env"`
+	Timeout     string   `yaml:"timeout"`
+	Services    []string `yaml:"services"` // if nil, all test services are used
+	SkipInCheck bool     `yaml:"skipInCheck"`
+}
+
+// FilterServices returns those of the given test services that this group needs.
+func (tg TestGroup) FilterServices(services []TestService) []TestService {
+	if tg.Services == nil {
+		return services
+	}
+	return slices.DeleteFunc(slices.Clone(services), func(s TestService) bool {
+		return !slices.Contains(tg.Services, s.Name)
+	})
+}
+
+var testGroupNameRx = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// BenchmarksConfiguration appears in type Configuration.
+type BenchmarksConfiguration struct {
+	Enabled  bool   `yaml:"enabled"`
+	Only     string `yaml:"only"`
+	Except   string `yaml:"except"`
+	Pattern  string `yaml:"pattern"`
+	Count    int    `yaml:"count"`
+	Baseline string `yaml:"baseline"`
+}
+
+// DefaultBenchmarkBaseline is the default for benchmarks.baseline.
+const DefaultBenchmarkBaseline = "testing/bench-baseline.txt"
+
+// Flags returns the flags for `go test` for running the benchmarks, with `$`
+// already escaped for use in the Makefile.
+func (b BenchmarksConfiguration) Flags() string {
+	pattern := strings.ReplaceAll(b.Pattern, "$", "$$")
+	if pattern == "" {
+		pattern = "."
+	}
+	count := b.Count
+	if count == 0 {
+		count = 10
+	}
+	return fmt.Sprintf("-run '^$$' -bench '%s' -benchmem -count %d", pattern, count)
+}
+
+// BaselinePath returns the path of the baseline file for `make bench-compare`.
+func (b BenchmarksConfiguration) BaselinePath() string {
+	if b.Baseline == "" {
+		return DefaultBenchmarkBaseline
+	}
+	return b.Baseline
+}
+
+// FuzzingConfiguration appears in type Configuration.
+type FuzzingConfiguration struct {
+	FuzzTime string `yaml:"fuzzTime"`
+}
+
+// DefaultFuzzTime is the default for fuzzing.fuzzTime.
+const DefaultFuzzTime = "1m"
+
+// FuzzTimeOrDefault returns the time budget for each fuzz test in `make fuzz`.
+func (f FuzzingConfiguration) FuzzTimeOrDefault() string {
+	if f.FuzzTime == "" {
+		return DefaultFuzzTime
+	}
+	return f.FuzzTime
+}
+
+var fuzzIterationsRx = regexp.MustCompile(`^[1-9][0-9]*x$`)
+
+// validateFuzzTime checks a value for `go test -fuzztime`, which is either a
+// duration or a number of iterations like "1000x".
+func validateFuzzTime(v *validator, path, value string) {
+	if value == "" || fuzzIterationsRx.MatchString(value) {
+		return
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		v.Errorf(path, "must be a duration like \"10m\" or a number of iterations like \"1000x\"")
+	}
+}
+
 // GolangConfiguration appears in type Configuration.
 type GolangConfiguration struct {
-	EnableVendoring bool `yaml:"enableVendoring"`
-	SetGoModVersion bool `yaml:"setGoModVersion"`
+	EnableVendoring bool   `yaml:"enableVendoring"`
+	SetGoModVersion bool   `yaml:"setGoModVersion"`
+	Toolchain       string `yaml:"toolchain"`
+	EnableCGO       *bool  `yaml:"enableCGO"` // this is a pointer to bool to fall back to ScanResult.UsesCGO if absent
+	NestedModules   bool   `yaml:"nestedModules"`
+}
+
+// UsesCGO returns whether the code shall be built with cgo. This is what the
+// scan detected, unless golang.enableCGO overrides it.
+func (g GolangConfiguration) UsesCGO(sr ScanResult) bool {
+	if g.EnableCGO != nil {
+		return *g.EnableCGO
+	}
+	return sr.UsesCGO
+}
+
+// CGOEnabled returns the value for CGO_ENABLED ("0" or "1"), or "" if
+// neither golang.enableCGO nor the scan decide it. The detection cannot rule
+// out cgo (e.g. in a dependency that it does not know about), so in that case,
+// the generated files do not set CGO_ENABLED and leave it to the go command.
+func (g GolangConfiguration) CGOEnabled(sr ScanResult) string {
+	switch {
+	case g.UsesCGO(sr):
+		return "1"
+	case g.EnableCGO != nil:
+		return "0"
+	default:
+		return ""
+	}
+}
+
+// ExplainCGO returns the reason for the result of UsesCGO() and CGOEnabled(),
+// for use with Explain().
+func (g GolangConfiguration) ExplainCGO(sr ScanResult) string {
+	switch {
+	case g.EnableCGO != nil:
+		return fmt.Sprintf("golang.enableCGO is set to %t", *g.EnableCGO)
+	case sr.UsesCGO:
+		return "cgo usage was detected in the Go files or the dependencies in go.mod"
+	default:
+		return "no cgo usage was detected in the Go files or the dependencies in go.mod, and golang.enableCGO is not set"
+	}
 }
 
 // GolangciLintConfiguration appears in type Configuration.
@@ -110,6 +369,29 @@ type SpellCheckConfiguration struct {
 ///////////////////////////////////////////////////////////////////////////////
 // GitHub workflow configuration
 
+// ToolVersions appears in type Configuration. It overrides the versions from
+// DefaultToolVersions.
+type ToolVersions map[string]string
+
+// Get returns the version for the given key from DefaultToolVersions.
+func (v ToolVersions) Get(key string) string {
+	if version := v[key]; version != "" {
+		return version
+	}
+	return DefaultToolVersions[key]
+}
+
+// Action returns the reference to the given GitHub action (as in the *Action
+// constants) with the version from Get().
+func (v ToolVersions) Action(action string) string {
+	repo, _ := splitAction(action)
+	if _, exists := DefaultToolVersions[repo]; !exists {
+		return action
+	}
+	name, _, _ := strings.Cut(action, "@")
+	return name + "@" + v.Get(repo)
+}
+
 // GithubWorkflowConfiguration appears in type Configuration.
 type GithubWorkflowConfiguration struct {
 	// These global-level settings are applicable for all workflows. They are
@@ -120,7 +402,13 @@ type GithubWorkflowConfiguration struct {
 	} `yaml:"global"`
 
 	CI                  CIWorkflowConfig             `yaml:"ci"`
-	IsSelfHostedRunner  bool                         `yaml:"omit"`
+	Fuzzing             FuzzingWorkflowConfig        `yaml:"fuzzing"`
+	IsSelfHostedRunner  bool                         `yaml:"-"`
+	PinActions          PinActionsConfig             `yaml:"pinActions"`
+	ToolVersions        ToolVersions                 `yaml:"-"` // copied from Configuration.ToolVersions
+	TestServices        []TestService                `yaml:"-"` // from TestServicesConfig.Services()
+	TestGroups          []TestGroup                  `yaml:"-"` // copied from Configuration.TestGroups
+	ActionLock          ActionLock                   `yaml:"-"` // copied from ScanResult.ActionLock
 	License             LicenseWorkflowConfig        `yaml:"license"`
 	PushContainerToGhcr PushContainerToGhcrConfig    `yaml:"pushContainerToGhcr"`
 	Release             ReleaseWorkflowConfig        `yaml:"release"`
@@ -134,6 +422,7 @@ type CIWorkflowConfig struct {
 	IgnorePaths []string `yaml:"ignorePaths"`
 	RunnerType  []string `yaml:"runOn"`
 	Coveralls   bool     `yaml:"coveralls"`
+	Benchmarks  bool     `yaml:"benchmarks"`
 	Postgres    struct {
 		Enabled bool   `yaml:"enabled"`
 		Version string `yaml:"version"`
@@ -144,6 +433,12 @@ type CIWorkflowConfig struct {
 	} `yaml:"kubernetesEnvtest"`
 }
 
+// FuzzingWorkflowConfig appears in type Configuration.
+type FuzzingWorkflowConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	FuzzTime string `yaml:"fuzzTime"`
+}
+
 // LicenseWorkflowConfig appears in type Configuration.
 type LicenseWorkflowConfig struct {
 	Enabled        bool     `yaml:"enabled"`
@@ -155,6 +450,12 @@ type PushContainerToGhcrConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+// PinActionsConfig appears in type Configuration.
+type PinActionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Strict  bool `yaml:"strict"`
+}
+
 type ReleaseWorkflowConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
@@ -191,6 +492,29 @@ type RenovateConfig struct {
 	PackageRules []PackageRule `yaml:"packageRules"`
 }
 
+// TestServicesConfig appears in type Configuration.
+type TestServicesConfig struct {
+	Enabled *bool    `yaml:"enabled"` // this is a pointer to bool to treat an absence as true
+	Exclude []string `yaml:"exclude"`
+}
+
+// Services returns the services from the TestServiceCatalog that were
+// detected by the scan and shall be provisioned for tests.
+func (t TestServicesConfig) Services(sr ScanResult) []TestService {
+	if t.Enabled != nil && !*t.Enabled {
+		return nil
+	}
+	var result []TestService
+	for _, name := range sr.TestServices {
+		if slices.Contains(t.Exclude, name) {
+			continue
+		}
+		s, _ := TestServiceByName(name)
+		result = append(result, s)
+	}
+	return result
+}
+
 // DockerfileConfig appears in type Configuration.
 type DockerfileConfig struct {
 	Enabled          bool     `yaml:"enabled"`
@@ -207,58 +531,265 @@ type MakefileConfig struct {
 	Enabled *bool `yaml:"enabled"` // this is a pointer to bool to treat an absence as true for backwards compatibility
 }
 
+// Metadata appears in type Configuration.
 type Metadata struct {
-	URL string `yaml:"url"`
+	URL    string `yaml:"url"`
+	Remote string `yaml:"remote"`
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Helper functions
 
-func (c *Configuration) Validate() {
+// fillDefaultsFromGit fills in the values that can be derived from the given
+// git repository if they are not set in the configuration file. This needs to
+// happen before validate(), which checks that these values are present.
+func (c *Configuration) fillDefaultsFromGit(v *validator, repo gitRepository) {
+	// metadata.url (Renovate can do without it, the other generators cannot)
+	if c.Metadata.Remote != "" && c.Metadata.URL != "" {
+		v.Warnf("metadata.remote", "has no effect because metadata.url is set")
+	}
+	needsURL := c.Dockerfile.Enabled || c.GoReleaser.CreateConfig || c.GitHubWorkflow != nil
+	if c.Metadata.URL == "" && (needsURL || c.Renovate.Enabled) {
+		remote := c.Metadata.Remote
+		if remote == "" {
+			remote = "origin"
+		}
+		url, err := repo.RemoteURL(remote)
+		if err == nil {
+			c.Metadata.URL = url
+			logg.Info("using metadata.url = %q derived from git remote %q", url, remote)
+		} else if needsURL {
+			v.Errorf("metadata.url", "could not be derived from the git remote, please set it manually: %s", err.Error())
+		}
+	}
+
+	// githubWorkflow.global.defaultBranch
+	if c.GitHubWorkflow != nil && c.GitHubWorkflow.Global.DefaultBranch == "" {
+		branch, err := repo.DefaultBranch()
+		if err == nil {
+			c.GitHubWorkflow.Global.DefaultBranch = branch
+		} else {
+			v.Errorf("githubWorkflow.global.defaultBranch", "could not find default branch using git, you can define it manually by setting 'githubWorkflow.global.defaultBranch' in config: %s", err.Error())
+		}
+	}
+}
+
+// validate checks the configuration for problems and reports them to the
+// given validator.
+func (c *Configuration) validate(v *validator) {
+	c.validateDockerfile(v)
+	c.validateGolang(v)
+	c.validateToolVersions(v)
+	c.validateTestServices(v)
+	c.validateTestGroups(v)
+	c.validateBenchmarks(v)
+	validateFuzzTime(v, "fuzzing.fuzzTime", c.Fuzzing.FuzzTime)
+	c.validateGoTest(v)
+	c.validateBinaries(v)
+	c.validateGolangciLint(v)
+	c.validateGoReleaser(v)
+	c.validateGitHubWorkflow(v)
+}
+
+func (c *Configuration) validateDockerfile(v *validator) {
 	if c.Dockerfile.Enabled {
 		if c.Metadata.URL == "" {
-			logg.Fatal("metadata.url must be set when docker.enabled is true")
+			v.Errorf("metadata.url", "must be set when dockerfile.enabled is true")
+		}
+		if len(c.Dockerfile.Entrypoint) == 0 && len(c.Binaries) == 0 && !c.AutoDetectBinaries {
+			v.Errorf("dockerfile.entrypoint", "must be set when dockerfile.enabled is true and no binaries are configured")
+		}
+	}
+	if c.Dockerfile.User != "" {
+		if c.Dockerfile.User == "root" {
+			v.Errorf("dockerfile.user", "this option has been removed; set `dockerfile.runAsRoot` if you need to run as root (run `go-makefile-maker migrate` to update the config)")
+		} else {
+			v.Errorf("dockerfile.user", "this option has been removed; commands now run as user `appuser` (ID 4200) in group `appgroup` (ID 4200) (run `go-makefile-maker migrate` to update the config)")
 		}
 	}
+}
 
-	// Validate GolangciLintConfiguration.
-	if len(c.GolangciLint.ErrcheckExcludes) > 0 && !c.GolangciLint.CreateConfig {
-		logg.Fatal("golangciLint.createConfig must be set to 'true' if golangciLint.errcheckExcludes is defined")
+func (c *Configuration) validateGolang(v *validator) {
+	if c.Golang.Toolchain != "" && c.Golang.Toolchain != DefaultToolchain && !toolchainRx.MatchString(c.Golang.Toolchain) {
+		v.Errorf("golang.toolchain", "must be a Go release like \"go1.21.4\", or %q to remove the toolchain directive", DefaultToolchain)
 	}
+}
 
-	// Validate GithubWorkflowConfiguration.
-	ghwCfg := c.GitHubWorkflow
-	if ghwCfg != nil {
-		if c.Metadata.URL == "" {
-			logg.Fatal("metadata.url must be set when any github workflow is configured otherwise it cannot be determined which github runner type should be used")
+// validateToolVersions only checks the values. Unknown keys are reported by
+// the schema check.
+func (c *Configuration) validateToolVersions(v *validator) {
+	for key, version := range c.ToolVersions {
+		if version == "" {
+			v.Errorf(joinPath("toolVersions", key), "must not be empty")
 		}
+	}
+}
 
-		// Validate global options.
-		if ghwCfg.Global.DefaultBranch == "" {
-			errMsg := "could not find default branch using git, you can define manually be setting 'githubWorkflow.global.defaultBranch' in config"
-			b, err := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD").CombinedOutput()
-			if err != nil {
-				logg.Fatal("%s: %s", errMsg, err.Error())
+func (c *Configuration) validateTestServices(v *validator) {
+	for idx, name := range c.TestServices.Exclude {
+		if _, exists := TestServiceByName(name); !exists {
+			v.Errorf(fmt.Sprintf("testServices.exclude.%d", idx), "unknown service %q (known services are: %s)", name, strings.Join(testServiceNames(), ", "))
+		}
+	}
+}
+
+func (c *Configuration) validateTestGroups(v *validator) {
+	for idx, tg := range c.TestGroups {
+		path := fmt.Sprintf("testGroups.%d", idx)
+		switch {
+		case tg.Name == "":
+			v.Errorf(path+".name", "must be set")
+		case !testGroupNameRx.MatchString(tg.Name):
+			v.Errorf(path+".name", "must only contain lowercase letters, digits and dashes")
+		case slices.ContainsFunc(c.TestGroups[:idx], func(other TestGroup) bool { return other.Name == tg.Name }):
+			v.Errorf(path+".name", "duplicate test group %q", tg.Name)
+		}
+		for envIdx, kv := range tg.Env {
+			if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+				v.Errorf(fmt.Sprintf("%s.env.%d", path, envIdx), "must have the form KEY=value")
 			}
+		}
+		if tg.Timeout != "" {
+			if _, err := time.ParseDuration(tg.Timeout); err != nil {
+				v.Errorf(path+".timeout", "must be a duration like \"10m\": %s", err.Error())
+			}
+		}
+		for serviceIdx, name := range tg.Services {
+			if _, exists := TestServiceByName(name); !exists {
+				v.Errorf(fmt.Sprintf("%s.services.%d", path, serviceIdx), "unknown service %q (known services are: %s)", name, strings.Join(testServiceNames(), ", "))
+			}
+		}
+	}
+	if len(c.TestGroups) > 0 && !slices.ContainsFunc(c.TestGroups, func(tg TestGroup) bool { return !tg.SkipInCheck }) {
+		v.Warnf("testGroups", "all test groups have skipInCheck set, so `make check` does not run any tests")
+	}
+	if len(c.TestGroups) > 0 {
+		// errors are ignored here since Scan() reports them later on
+		moduleDirs, _, err := findModuleDirs(v.repoDir, c.Golang.NestedModules)
+		if err == nil && len(moduleDirs) > 1 {
+			v.Errorf("testGroups", "cannot be used in repositories with multiple modules (found modules in %s)", strings.Join(moduleDirs, ", "))
+		}
+	}
+}
 
-			branch := strings.TrimPrefix(string(b), "refs/remotes/origin/")
-			if branch == string(b) {
-				logg.Fatal(errMsg)
-			} else {
-				c.GitHubWorkflow.Global.DefaultBranch = strings.TrimSpace(branch)
+func (c *Configuration) validateBenchmarks(v *validator) {
+	if c.Benchmarks.Count < 0 {
+		v.Errorf("benchmarks.count", "must not be negative")
+	}
+	if strings.Contains(c.Benchmarks.Pattern, "'") {
+		v.Errorf("benchmarks.pattern", "must not contain single quotes")
+	}
+	if !c.Benchmarks.Enabled && (c.Benchmarks.Only != "" || c.Benchmarks.Except != "" || c.Benchmarks.Pattern != "" || c.Benchmarks.Count != 0 || c.Benchmarks.Baseline != "") {
+		v.Warnf("benchmarks", "has no effect unless benchmarks.enabled is set")
+	}
+}
+
+func (c *Configuration) validateGoTest(v *validator) {
+	if c.GoTest.Parallelism < 0 {
+		v.Errorf("goTest.parallelism", "must not be negative")
+	}
+	if c.GoTest.Count < 0 {
+		v.Errorf("goTest.count", "must not be negative")
+	}
+	if c.GoTest.Timeout != "" {
+		if _, err := time.ParseDuration(c.GoTest.Timeout); err != nil {
+			v.Errorf("goTest.timeout", "must be a duration like \"10m\": %s", err.Error())
+		}
+	}
+	if s := c.GoTest.Shuffle; s != "" && s != "on" && s != "off" {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			v.Errorf("goTest.shuffle", "must be \"on\", \"off\" or an integer seed")
+		}
+	}
+}
+
+// validateBinaries also covers crossCompile and autoBinaries, since both
+// only make sense together with the binaries.
+func (c *Configuration) validateBinaries(v *validator) {
+	for idx, bin := range c.Binaries {
+		for envIdx, kv := range bin.Env {
+			key, _, ok := strings.Cut(kv, "=")
+			switch {
+			case !ok || key == "":
+				v.Errorf(fmt.Sprintf("binaries.%d.env.%d", idx, envIdx), "must have the form KEY=value")
+			case key == "GOOS" || key == "GOARCH":
+				v.Errorf(fmt.Sprintf("binaries.%d.env.%d", idx, envIdx), "use binaries.%s instead of setting %s in env", strings.ToLower(key), key)
 			}
 		}
+	}
 
-		// Validate CI workflow configuration.
-		if ghwCfg.CI.Postgres.Enabled || ghwCfg.CI.KubernetesEnvtest.Enabled {
-			if !ghwCfg.CI.Enabled {
-				logg.Fatal("githubWorkflow.ci.enabled must be set to 'true' when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled")
+	for idx, platform := range c.CrossCompile {
+		goos, goarch, ok := strings.Cut(platform, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			v.Errorf(fmt.Sprintf("crossCompile.%d", idx), "must have the form GOOS/GOARCH, e.g. \"linux/arm64\"")
+		}
+	}
+	if len(c.CrossCompile) > 0 && len(c.Binaries) == 0 && !c.AutoDetectBinaries {
+		v.Warnf("crossCompile", "has no effect when no binaries are configured")
+	}
+	if len(c.CrossCompile) > 0 {
+		for idx, bin := range c.Binaries {
+			if bin.GOOS != "" || bin.GOARCH != "" {
+				v.Warnf(fmt.Sprintf("binaries.%d", idx), "is not built by `make build-cross` because it sets goos or goarch")
 			}
-			if len(ghwCfg.CI.RunnerType) > 0 {
-				if len(ghwCfg.CI.RunnerType) > 1 || !strings.HasPrefix(ghwCfg.CI.RunnerType[0], "ubuntu") {
-					logg.Fatal("githubWorkflow.ci.runOn must only define a single Ubuntu based runner when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled")
-				}
+		}
+	}
+
+	if !c.AutoDetectBinaries && (len(c.AutoBinaries.Exclude) > 0 || c.AutoBinaries.InstallTo != "") {
+		v.Warnf("autoBinaries", "has no effect unless `binaries: auto` is set")
+	}
+}
+
+func (c *Configuration) validateGolangciLint(v *validator) {
+	if len(c.GolangciLint.ErrcheckExcludes) > 0 && !c.GolangciLint.CreateConfig {
+		v.Errorf("golangciLint.errcheckExcludes", "golangciLint.createConfig must be set to 'true' if golangciLint.errcheckExcludes is defined")
+	}
+}
+
+func (c *Configuration) validateGoReleaser(v *validator) {
+	if c.GoReleaser.CreateConfig {
+		if len(c.Binaries) == 0 && !c.AutoDetectBinaries {
+			v.Errorf("goReleaser.createConfig", "requires at least one entry in binaries")
+		}
+		if c.Metadata.URL == "" {
+			v.Errorf("metadata.url", "must be set when goReleaser.createConfig is true")
+		}
+	}
+}
+
+func (c *Configuration) validateGitHubWorkflow(v *validator) {
+	ghwCfg := c.GitHubWorkflow
+	if ghwCfg == nil {
+		return
+	}
+	if c.Metadata.URL == "" {
+		v.Errorf("metadata.url", "must be set when any github workflow is configured otherwise it cannot be determined which github runner type should be used")
+	}
+	if ghwCfg.PinActions.Strict && !ghwCfg.PinActions.Enabled {
+		v.Warnf("githubWorkflow.pinActions.strict", "has no effect unless githubWorkflow.pinActions.enabled is set")
+	}
+
+	// Validate CI workflow configuration.
+	if ghwCfg.CI.Postgres.Enabled || ghwCfg.CI.KubernetesEnvtest.Enabled {
+		if !ghwCfg.CI.Enabled {
+			v.Errorf("githubWorkflow.ci.enabled", "must be set to 'true' when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled")
+		}
+		if len(ghwCfg.CI.RunnerType) > 0 {
+			if len(ghwCfg.CI.RunnerType) > 1 || !strings.HasPrefix(ghwCfg.CI.RunnerType[0], "ubuntu") {
+				v.Errorf("githubWorkflow.ci.runOn", "must only define a single Ubuntu based runner when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled")
 			}
 		}
 	}
+	if ghwCfg.CI.Benchmarks && !c.Benchmarks.Enabled {
+		v.Errorf("githubWorkflow.ci.benchmarks", "must not be set unless benchmarks.enabled is set")
+	}
+	validateFuzzTime(v, "githubWorkflow.fuzzing.fuzzTime", ghwCfg.Fuzzing.FuzzTime)
+
+	// These combinations work, but are most likely not what the user wants.
+	if ghwCfg.Release.Enabled && !c.GoReleaser.CreateConfig {
+		v.Warnf("githubWorkflow.release.enabled", "the release workflow runs goreleaser, but goReleaser.createConfig is not set")
+	}
+	if ghwCfg.PushContainerToGhcr.Enabled && !c.Dockerfile.Enabled {
+		v.Warnf("githubWorkflow.pushContainerToGhcr.enabled", "the workflow builds the Dockerfile, but dockerfile.enabled is not set")
+	}
 }
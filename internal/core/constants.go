@@ -14,12 +14,17 @@
 
 package core
 
+import "strings"
+
 const (
 	DefaultAlpineImage       = "3.18"
 	DefaultGolangImagePrefix = "1.21.4-alpine"
 
 	DefaultGoVersion           = "1.21"
 	DefaultPostgresVersion     = "12"
+	DefaultMariaDBVersion      = "11"
+	DefaultRedisVersion        = "7"
+	DefaultNATSVersion         = "2"
 	DefaultLinkerdAwaitVersion = "0.2.7"
 	DefaultK8sEnvtestVersion   = "1.26.x!"
 	DefaultGitHubComRunnerType = "ubuntu-latest"
@@ -32,6 +37,7 @@ const (
 	CheckoutAction         = "actions/checkout@v4"
 	SetupGoAction          = "actions/setup-go@v4"
 	DependencyReviewAction = "actions/dependency-review-action@v3"
+	UploadArtifactAction   = "actions/upload-artifact@v3"
 
 	DockerLoginAction     = "docker/login-action@v3"
 	DockerMetadataAction  = "docker/metadata-action@v5"
@@ -46,3 +52,60 @@ const (
 	GovulncheckAction  = "golang/govulncheck-action@v1"
 	MisspellAction     = "reviewdog/action-misspell@v1"
 )
+
+// allActions contains all GitHub actions that are used in generated workflows.
+var allActions = []string{
+	CacheAction, CheckoutAction, SetupGoAction, DependencyReviewAction, UploadArtifactAction,
+	DockerLoginAction, DockerMetadataAction, DockerBuildPushAction,
+	CodeqlInitAction, CodeqlAnalyzeAction, CodeqlAutobuildAction,
+	GolangciLintAction, GoreleaserAction, GovulncheckAction, MisspellAction,
+}
+
+// DefaultToolVersions contains all keys that are accepted in `toolVersions`,
+// and the version that is used for each of them unless overridden.
+var DefaultToolVersions = defaultToolVersions()
+
+// toolVersionDocs describes the keys in `toolVersions` that are not GitHub actions.
+var toolVersionDocs = map[string]string{
+	"alpine":        "Tag of the Alpine base image in the Dockerfile. Also used for the builder image.",
+	"go":            "Go version that golang.setGoModVersion puts into go.mod.",
+	"golang":        "Tag prefix of the golang builder image in the Dockerfile (the Alpine version is appended to it). Takes precedence over the toolchain directive in go.mod.",
+	"k8s-envtest":   "Version of the envtest binaries for githubWorkflow.ci.kubernetesEnvtest and the k8s-envtest test service.",
+	"linkerd-await": "Version of linkerd-await for dockerfile.withLinkerdAwait.",
+	"mariadb":       "Tag of the mariadb image for the mariadb test service.",
+	"nats":          "Tag of the nats image for the nats test service.",
+	"postgres":      "Tag of the postgres image for githubWorkflow.ci.postgres and the postgres test service.",
+	"redis":         "Tag of the redis image for the redis test service.",
+}
+
+func defaultToolVersions() map[string]string {
+	result := map[string]string{
+		"alpine":        DefaultAlpineImage,
+		"go":            DefaultGoVersion,
+		"golang":        DefaultGolangImagePrefix,
+		"k8s-envtest":   DefaultK8sEnvtestVersion,
+		"linkerd-await": DefaultLinkerdAwaitVersion,
+		"mariadb":       DefaultMariaDBVersion,
+		"nats":          DefaultNATSVersion,
+		"postgres":      DefaultPostgresVersion,
+		"redis":         DefaultRedisVersion,
+	}
+	// for actions, the key is the action's repository and the version is the Git ref
+	for _, action := range allActions {
+		key, ref := splitAction(action)
+		result[key] = ref
+	}
+	return result
+}
+
+// splitAction splits a reference to a GitHub action like
+// "github/codeql-action/init@v2" into the repository ("github/codeql-action")
+// and the Git ref ("v2").
+func splitAction(action string) (repo, ref string) {
+	name, ref, _ := strings.Cut(action, "@")
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/"), ref
+}
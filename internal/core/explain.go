@@ -0,0 +1,99 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Explainer is implemented by output sinks that want to know why each output
+// was generated the way it was. Renderers report their decisions through
+// Explain(), which does nothing for sinks that do not implement this interface.
+type Explainer interface {
+	Explain(subject, reason string)
+}
+
+// Explain reports to the given sink why the given subject was generated (or
+// not generated). A subject is either the path of an output file, or a path
+// followed by ":" and a part of that file, e.g. "Makefile:build/foo" for a
+// Makefile target or ".github/workflows/ci.yaml:test" for a workflow job.
+func Explain(sink OutputSink, subject, reason string, args ...any) {
+	if e, ok := sink.(Explainer); ok {
+		if len(args) > 0 {
+			reason = fmt.Sprintf(reason, args...)
+		}
+		e.Explain(subject, reason)
+	}
+}
+
+// ExplainSink is an OutputSink that holds outputs in memory like MemorySink,
+// and also records the explanations from Explain().
+type ExplainSink struct {
+	*MemorySink
+	subjects []string
+	reasons  map[string][]string
+}
+
+// NewExplainSink builds a new ExplainSink.
+func NewExplainSink() *ExplainSink {
+	return &ExplainSink{MemorySink: NewMemorySink(), reasons: make(map[string][]string)}
+}
+
+// Explain implements the Explainer interface.
+func (s *ExplainSink) Explain(subject, reason string) {
+	if _, exists := s.reasons[subject]; !exists {
+		s.subjects = append(s.subjects, subject)
+	}
+	s.reasons[subject] = append(s.reasons[subject], reason)
+}
+
+// Explanation appears in the result of ExplainSink.Query().
+type Explanation struct {
+	Subject string
+	Reasons []string
+}
+
+// Query returns the explanations for all subjects matching the given query,
+// in the order in which they were first reported. The query can be a file
+// path (e.g. "Makefile"), the name of a workflow (e.g. "ci"), the part of a
+// subject after the colon (e.g. "build/foo" for a Makefile target), or an
+// entire subject. If the query is empty, all explanations are returned.
+func (s *ExplainSink) Query(query string) []Explanation {
+	var result []Explanation
+	for _, subject := range s.subjects {
+		if query == "" || subjectMatches(subject, query) {
+			result = append(result, Explanation{subject, s.reasons[subject]})
+		}
+	}
+	return result
+}
+
+func subjectMatches(subject, query string) bool {
+	filePath, part, _ := strings.Cut(subject, ":")
+	if subject == query || filePath == query || part == query {
+		return true
+	}
+	// workflows can be referenced by name, e.g. "ci" for ".github/workflows/ci.yaml"
+	if dir, file := path.Split(filePath); dir == ".github/workflows/" {
+		return strings.TrimSuffix(file, path.Ext(file)) == query
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExplainSinkQuery(t *testing.T) {
+	sink := NewExplainSink()
+	Explain(sink, "Makefile", "makefile.enabled is not set to false")
+	Explain(sink, "Makefile:build/foo", "binaries contains an entry for %s", "foo")
+	Explain(sink, ".github/workflows/ci.yaml", "githubWorkflow.ci.enabled is set")
+	Explain(sink, ".github/workflows/ci.yaml:test", "always generated")
+
+	testCases := map[string][]string{
+		"":                          {"Makefile", "Makefile:build/foo", ".github/workflows/ci.yaml", ".github/workflows/ci.yaml:test"},
+		"Makefile":                  {"Makefile", "Makefile:build/foo"},
+		"build/foo":                 {"Makefile:build/foo"},
+		"ci":                        {".github/workflows/ci.yaml", ".github/workflows/ci.yaml:test"},
+		".github/workflows/ci.yaml": {".github/workflows/ci.yaml", ".github/workflows/ci.yaml:test"},
+		"test":                      {".github/workflows/ci.yaml:test"},
+		"Dockerfile":                nil,
+	}
+	for query, expected := range testCases {
+		var actual []string
+		for _, e := range sink.Query(query) {
+			actual = append(actual, e.Subject)
+		}
+		if !slices.Equal(actual, expected) {
+			t.Errorf("expected query %q to match %v, but got %v", query, expected, actual)
+		}
+	}
+
+	if e := sink.Query("build/foo"); len(e) == 1 && e[0].Reasons[0] != "binaries contains an entry for foo" {
+		t.Errorf("unexpected reasons for build/foo: %v", e[0].Reasons)
+	}
+
+	// sinks that do not implement Explainer are ignored
+	Explain(NewMemorySink(), "Makefile", "this must not panic")
+}
@@ -0,0 +1,278 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// appendTag can be put on a list in a config file to append its items to the
+// list from the base file(s), instead of replacing it.
+const appendTag = "!append"
+
+// configLoader reads a config file and all base files that it extends, and
+// merges them into a single YAML document.
+//
+// Precedence rules: Files listed in `extends` are merged in order, and the
+// extending file is merged on top of them. When merging, maps are merged
+// recursively. All other values (including lists) are replaced, except for
+// lists tagged with `!append`, which are appended to the base list. An
+// explicit `null` removes the key from the result.
+type configLoader struct {
+	// origins records which file each node comes from, for error reporting
+	origins map[*yaml.Node]string
+	// loading contains the files that are currently being loaded, to detect cycles
+	loading []string
+}
+
+func newConfigLoader() *configLoader {
+	return &configLoader{origins: make(map[*yaml.Node]string)}
+}
+
+// load parses the given config file and merges it with its base files. It
+// returns the top-level node of the merged document, or nil if the document is
+// empty. If there is an error that prevents the config from being merged, nil
+// is returned as well.
+func (l *configLoader) load(fileName string, buf []byte) (*yaml.Node, ValidationIssues) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(buf, &doc)
+	if err != nil {
+		issue := issueFromYAMLError(err.Error())
+		issue.File = fileName
+		return nil, ValidationIssues{issue}
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	l.recordOrigin(root, fileName)
+
+	extendsKey, extendsValue := takeKey(root, "extends")
+	issues := typeCheck(fileName, root)
+	if extendsValue == nil {
+		return root, issues
+	}
+
+	extendsIssue := func(msg string, args ...any) ValidationIssues {
+		return append(issues, ValidationIssue{
+			File:    fileName,
+			Path:    "extends",
+			Line:    extendsKey.Line,
+			Column:  extendsKey.Column,
+			Message: fmt.Sprintf(msg, args...),
+		})
+	}
+
+	var baseFiles []string
+	switch extendsValue.Kind {
+	case yaml.ScalarNode:
+		baseFiles = []string{extendsValue.Value}
+	case yaml.SequenceNode:
+		for _, item := range extendsValue.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, extendsIssue("must be a file name or a list of file names")
+			}
+			baseFiles = append(baseFiles, item.Value)
+		}
+	default:
+		return nil, extendsIssue("must be a file name or a list of file names")
+	}
+
+	l.loading = append(l.loading, filepath.Clean(fileName))
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	var base *yaml.Node
+	for _, baseFile := range baseFiles {
+		// paths are relative to the file containing the `extends` key
+		basePath := filepath.FromSlash(baseFile)
+		if !filepath.IsAbs(basePath) {
+			basePath = filepath.Join(filepath.Dir(fileName), basePath)
+		}
+		if slices.Contains(l.loading, filepath.Clean(basePath)) {
+			return nil, extendsIssue("%s is extended recursively", baseFile)
+		}
+		baseBuf, err := os.ReadFile(basePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, extendsIssue("base file %s does not exist", basePath)
+			}
+			return nil, extendsIssue("cannot read base file: %s", err.Error())
+		}
+
+		baseRoot, baseIssues := l.load(basePath, baseBuf)
+		issues = append(issues, baseIssues...)
+		if baseRoot == nil && baseIssues.HasErrors() {
+			return nil, issues
+		}
+		base = l.merge(base, baseRoot)
+	}
+	return l.merge(base, root), issues
+}
+
+// recordOrigin records the given file name for the given node and all nodes below it.
+func (l *configLoader) recordOrigin(node *yaml.Node, fileName string) {
+	l.origins[node] = fileName
+	for _, child := range node.Content {
+		l.recordOrigin(child, fileName)
+	}
+}
+
+// merge merges the override node on top of the base node according to the
+// rules explained on type configLoader. Existing nodes are not modified.
+func (l *configLoader) merge(base, override *yaml.Node) *yaml.Node {
+	switch {
+	case base == nil:
+		return override
+	case override == nil:
+		return base
+	case base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode:
+		result := &yaml.Node{
+			Kind:   yaml.MappingNode,
+			Tag:    override.Tag,
+			Line:   override.Line,
+			Column: override.Column,
+		}
+		l.origins[result] = l.origins[override]
+		result.Content = slices.Clone(base.Content)
+		for idx := 0; idx+1 < len(override.Content); idx += 2 {
+			keyNode, valueNode := override.Content[idx], override.Content[idx+1]
+			existingIdx := -1
+			for resultIdx := 0; resultIdx+1 < len(result.Content); resultIdx += 2 {
+				if result.Content[resultIdx].Value == keyNode.Value {
+					existingIdx = resultIdx
+					break
+				}
+			}
+
+			switch {
+			case isNull(valueNode):
+				if existingIdx >= 0 {
+					result.Content = slices.Delete(result.Content, existingIdx, existingIdx+2)
+				}
+			case existingIdx >= 0:
+				result.Content[existingIdx] = keyNode
+				result.Content[existingIdx+1] = l.merge(result.Content[existingIdx+1], valueNode)
+			default:
+				result.Content = append(result.Content, keyNode, valueNode)
+			}
+		}
+		return result
+	case base.Kind == yaml.SequenceNode && override.Kind == yaml.SequenceNode && override.Tag == appendTag:
+		result := &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Style:   override.Style,
+			Line:    override.Line,
+			Column:  override.Column,
+			Content: append(slices.Clone(base.Content), override.Content...),
+		}
+		l.origins[result] = l.origins[override]
+		return result
+	default:
+		return override
+	}
+}
+
+// takeKey removes the given key from a mapping node, and returns its key and
+// value nodes (or nil if the key does not exist).
+func takeKey(node *yaml.Node, key string) (keyNode, valueNode *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if node.Content[idx].Value == key {
+			keyNode, valueNode = node.Content[idx], node.Content[idx+1]
+			node.Content = slices.Delete(node.Content, idx, idx+2)
+			return keyNode, valueNode
+		}
+	}
+	return nil, nil
+}
+
+// typeCheck decodes a single config file to find type errors. We do this
+// for each file separately because errors from decoding the merged document
+// could not be attributed to the right file. (Merging never changes the type
+// of a value, so the merged document does not have any other type errors.)
+func typeCheck(fileName string, root *yaml.Node) ValidationIssues {
+	var (
+		cfg    Configuration
+		issues ValidationIssues
+	)
+	node, _ := withoutAutoBinaries(root)
+	err := node.Decode(&cfg)
+	var typeErr *yaml.TypeError
+	switch {
+	case errors.As(err, &typeErr):
+		for _, msg := range typeErr.Errors {
+			issue := issueFromYAMLError(msg)
+			issue.File = fileName
+			issues = append(issues, issue)
+		}
+	case err != nil:
+		issues = append(issues, ValidationIssue{File: fileName, Message: err.Error()})
+	}
+	return issues
+}
+
+// stripAppendTags removes the `!append` tags below the given node, so that
+// they do not show up in the output of EffectiveConfigYAML().
+func stripAppendTags(node *yaml.Node) {
+	if node.Tag == appendTag {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		stripAppendTags(child)
+	}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// EffectiveConfigYAML returns the contents of the config file at the given
+// path after merging all base files referenced via `extends`.
+func EffectiveConfigYAML(path string) ([]byte, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, issues := newConfigLoader().load(path, buf)
+	if issues.HasErrors() {
+		return nil, fmt.Errorf("%s is not valid", path)
+	}
+	if root == nil {
+		return nil, nil
+	}
+	stripAppendTags(root)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	err = enc.Encode(root)
+	if err == nil {
+		err = enc.Close()
+	}
+	return out.Bytes(), err
+}
@@ -0,0 +1,131 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, contents := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		err := os.MkdirAll(filepath.Dir(fullPath), 0o755)
+		if err == nil {
+			err = os.WriteFile(fullPath, []byte(contents), 0o666)
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func TestExtendsMergesBaseFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"shared/org.yaml": `
+golangciLint:
+  createConfig: true
+  skipDirs: [ generated ]
+spellCheck:
+  ignoreWords: [ sapcc ]
+dockerfile:
+  enabled: true
+  extraPackages: [ curl ]
+renovate:
+  enabled: true
+  goVersion: "1.20"
+`,
+		"shared/team.yaml": `
+renovate:
+  goVersion: "1.21"
+  assignees: [ team-lead ]
+`,
+		"Makefile.maker.yaml": `
+extends: [ shared/org.yaml, shared/team.yaml ]
+golangciLint:
+  skipDirs: [ other ]
+spellCheck:
+  ignoreWords: !append [ foobar ]
+dockerfile: null
+`,
+	})
+
+	cfg, issues := LoadConfiguration(filepath.Join(root, "Makefile.maker.yaml"))
+	for _, issue := range issues {
+		t.Error(issue.Format(ConfigFilename))
+	}
+
+	if !cfg.GolangciLint.CreateConfig || !reflect.DeepEqual(cfg.GolangciLint.SkipDirs, []string{"other"}) {
+		t.Errorf("expected golangciLint to be merged with replaced skipDirs, but got %#v", cfg.GolangciLint)
+	}
+	if !reflect.DeepEqual(cfg.SpellCheck.IgnoreWords, []string{"sapcc", "foobar"}) {
+		t.Errorf("expected spellCheck.ignoreWords to be appended, but got %#v", cfg.SpellCheck.IgnoreWords)
+	}
+	if cfg.Dockerfile.Enabled || len(cfg.Dockerfile.ExtraPackages) > 0 {
+		t.Errorf("expected dockerfile to be unset, but got %#v", cfg.Dockerfile)
+	}
+	if !cfg.Renovate.Enabled || cfg.Renovate.GoVersion != "1.21" || !reflect.DeepEqual(cfg.Renovate.Assignees, []string{"team-lead"}) {
+		t.Errorf("expected later base files to take precedence, but got %#v", cfg.Renovate)
+	}
+}
+
+func TestExtendsReportsIssuesInBaseFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"base.yaml": `
+golang:
+  enableVendoring: maybe
+golangciLint:
+  skipDir: [ generated ]
+`,
+		"cycle.yaml":  "extends: cycle2.yaml\n",
+		"cycle2.yaml": "extends: cycle.yaml\n",
+		"Makefile.maker.yaml": `
+extends: base.yaml
+spellCheck:
+  ignoreWord: [ foo ]
+`,
+	})
+
+	check := func(path string, expected ...string) {
+		t.Helper()
+		_, issues := LoadConfiguration(filepath.Join(root, path))
+		var actual []string
+		for _, issue := range issues {
+			actual = append(actual, strings.TrimPrefix(issue.Format(path), root+string(filepath.Separator)))
+		}
+		if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+
+	check("Makefile.maker.yaml",
+		"base.yaml:3: cannot unmarshal !!str `maybe` into bool",
+		`base.yaml:5:3: golangciLint.skipDir: unknown key (did you mean "skipDirs"?)`,
+		`Makefile.maker.yaml:4:3: spellCheck.ignoreWord: unknown key (did you mean "ignoreWords"?)`,
+	)
+	check("cycle2.yaml",
+		"cycle.yaml:1:1: extends: cycle2.yaml is extended recursively",
+	)
+}
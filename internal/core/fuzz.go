@@ -0,0 +1,163 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sapcc/go-bits/logg"
+)
+
+// FuzzTarget is a fuzz test (`func FuzzXxx(f *testing.F)`) that was found in
+// one of the test files of the repository.
+type FuzzTarget struct {
+	Name    string     // e.g. "FuzzParse"
+	Module  ModuleInfo // the module containing the package
+	Package string     // relative to the module directory, e.g. "./internal/parser" or "."
+}
+
+// ImportPath returns the import path of the package containing the fuzz test.
+func (t FuzzTarget) ImportPath() string {
+	return path.Join(t.Module.Path, t.Package)
+}
+
+// CorpusDir returns the directory (relative to the module directory) in which
+// `go test` looks for the seed corpus and puts failing inputs.
+func (t FuzzTarget) CorpusDir() string {
+	return t.Package + "/testdata/fuzz/" + t.Name
+}
+
+// detectFuzzTargets returns all fuzz tests in the test files of the given
+// modules. Like in detectCGO(), build constraints are not evaluated.
+func detectFuzzTargets(root string, modules []ModuleInfo) ([]FuzzTarget, error) {
+	var result []FuzzTarget
+	fset := token.NewFileSet()
+	for _, m := range modules {
+		moduleRoot := filepath.Join(root, filepath.FromSlash(m.Dir))
+		err := filepath.WalkDir(moduleRoot, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if filePath == moduleRoot {
+					return nil
+				}
+				// nested modules are checked separately if they are part of the repository's modules
+				if isIgnoredDir(d.Name()) || fileExists(filepath.Join(filePath, ModFilename)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			name := d.Name()
+			if !strings.HasSuffix(name, "_test.go") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return nil
+			}
+
+			file, err := parser.ParseFile(fset, filePath, nil, parser.SkipObjectResolution)
+			if err != nil {
+				// like in detectCGO(), this is reported by the go command anyway
+				logg.Other("WARNING", "cannot check %s for fuzz tests: %s", filePath, err.Error())
+				return nil
+			}
+			relDir, err := filepath.Rel(moduleRoot, filepath.Dir(filePath))
+			if err != nil {
+				return err
+			}
+			for _, funcName := range findFuzzFuncs(file) {
+				result = append(result, FuzzTarget{
+					Name:    funcName,
+					Module:  m,
+					Package: cleanPackagePath(filepath.ToSlash(relDir)),
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// findFuzzFuncs returns the names of all functions in the given file that
+// `go test` considers to be fuzz tests.
+func findFuzzFuncs(file *ast.File) []string {
+	// the "testing" package may have been imported under a different name
+	testingName := ""
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || importPath != "testing" {
+			continue
+		}
+		testingName = "testing"
+		if spec.Name != nil {
+			testingName = spec.Name.Name
+		}
+	}
+	if testingName == "" || testingName == "_" {
+		return nil
+	}
+
+	var result []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !isFuzzFuncName(fn.Name.Name) {
+			continue
+		}
+		params := fn.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) > 1 {
+			continue
+		}
+		star, ok := params[0].Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+		sel, ok := star.X.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "F" {
+			continue
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == testingName {
+			result = append(result, fn.Name.Name)
+		}
+	}
+	return result
+}
+
+// isFuzzFuncName returns whether `go test` accepts the given name for a fuzz
+// test, i.e. "Fuzz" is not followed by a lowercase letter.
+func isFuzzFuncName(name string) bool {
+	suffix, ok := strings.CutPrefix(name, "Fuzz")
+	if !ok {
+		return false
+	}
+	if suffix == "" {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(suffix)
+	return !unicode.IsLower(r)
+}
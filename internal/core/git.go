@@ -0,0 +1,97 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// GitRepo runs git commands in the repository that contains the directory
+// Dir. It is used to derive default values for the configuration. Nothing is
+// run until one of its methods is called, so repositories that set all these
+// values explicitly do not need git at all.
+type GitRepo struct {
+	Dir string
+}
+
+// gitRepository is the interface of GitRepo that is used by the config
+// loader. It allows replacing GitRepo in tests.
+type gitRepository interface {
+	RemoteURL(remote string) (string, error)
+	DefaultBranch() (string, error)
+}
+
+// newGitRepo is a variable to allow replacing it in tests.
+var newGitRepo = func(dir string) gitRepository { return GitRepo{Dir: dir} }
+
+// RemoteURL returns the canonical HTTPS URL (e.g. "https://github.com/foo/bar")
+// of the given git remote.
+func (r GitRepo) RemoteURL(remote string) (string, error) {
+	out, err := exec.Command("git", "-C", r.Dir, "remote", "get-url", remote).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not get URL of git remote %q: %w", remote, err)
+	}
+	return CanonicalRepoURL(strings.TrimSpace(string(out)))
+}
+
+// DefaultBranch returns the name of the default branch of the "origin" remote
+// (i.e. the branch that "refs/remotes/origin/HEAD" points to).
+func (r GitRepo) DefaultBranch() (string, error) {
+	out, err := exec.Command("git", "-C", r.Dir, "symbolic-ref", "refs/remotes/origin/HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(out)), "refs/remotes/origin/")
+	if !ok || branch == "" {
+		return "", fmt.Errorf("unexpected output from git symbolic-ref: %q", strings.TrimSpace(string(out)))
+	}
+	return branch, nil
+}
+
+// matches the scp-like syntax that git accepts for SSH remotes, e.g. "git@github.com:foo/bar.git"
+var scpLikeRemoteRx = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// CanonicalRepoURL converts a git remote URL in any of the forms understood by
+// git (HTTPS, SSH, or scp-like) into the HTTPS URL of the repository's web
+// page, e.g. "https://github.com/foo/bar".
+func CanonicalRepoURL(remoteURL string) (string, error) {
+	var host, repoPath string
+	if u, err := url.Parse(remoteURL); err == nil && u.Scheme != "" && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+			host, repoPath = u.Hostname(), u.Path
+		default:
+			return "", fmt.Errorf("unsupported scheme in git remote URL %q", remoteURL)
+		}
+	} else if match := scpLikeRemoteRx.FindStringSubmatch(remoteURL); match != nil && !strings.Contains(remoteURL, "://") {
+		host, repoPath = match[1], match[2]
+	} else {
+		return "", fmt.Errorf("cannot parse git remote URL %q", remoteURL)
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || repoPath == "" {
+		return "", fmt.Errorf("cannot parse git remote URL %q", remoteURL)
+	}
+	return fmt.Sprintf("https://%s/%s", host, repoPath), nil
+}
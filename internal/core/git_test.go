@@ -0,0 +1,48 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import "testing"
+
+func TestCanonicalRepoURL(t *testing.T) {
+	testCases := map[string]string{
+		"https://github.com/sapcc/go-makefile-maker":       "https://github.com/sapcc/go-makefile-maker",
+		"https://github.com/sapcc/go-makefile-maker.git":   "https://github.com/sapcc/go-makefile-maker",
+		"https://user@github.com/sapcc/go-makefile-maker/": "https://github.com/sapcc/go-makefile-maker",
+		"ssh://git@github.wdf.sap.corp:2222/cc/foo.git":    "https://github.wdf.sap.corp/cc/foo",
+		"git@github.com:sapcc/go-makefile-maker.git":       "https://github.com/sapcc/go-makefile-maker",
+		"github.example.com:org/repo":                      "https://github.example.com/org/repo",
+		"git://github.com/sapcc/go-makefile-maker":         "https://github.com/sapcc/go-makefile-maker",
+	}
+	for input, expected := range testCases {
+		actual, err := CanonicalRepoURL(input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", input, err.Error())
+		} else if actual != expected {
+			t.Errorf("expected %q to become %q, but got %q", input, expected, actual)
+		}
+	}
+
+	for _, input := range []string{"", "/some/local/path", "file:///some/local/path"} {
+		_, err := CanonicalRepoURL(input)
+		if err == nil {
+			t.Errorf("expected error for %q, but got none", input)
+		}
+	}
+}
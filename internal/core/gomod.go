@@ -0,0 +1,77 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"regexp"
+
+	"golang.org/x/mod/modfile"
+)
+
+// DefaultToolchain can be given as `golang.toolchain` to remove the toolchain
+// directive from go.mod. This is the same keyword that the go command accepts.
+const DefaultToolchain = "default"
+
+var toolchainRx = regexp.MustCompile(`^go1(\.\d+){1,2}((rc|beta)\d+)?$`)
+
+// UpdateGoMod updates the "go" directive (if goVersion is not empty) and the
+// "toolchain" directive (if toolchain is not empty) in the given go.mod file.
+// If toolchain is DefaultToolchain, the toolchain directive is removed.
+// All other contents of the file, including comments, are preserved.
+func UpdateGoMod(fileName string, buf []byte, goVersion, toolchain string) ([]byte, error) {
+	modFile, err := modfile.Parse(fileName, buf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if goVersion != "" && (modFile.Go == nil || modFile.Go.Version != goVersion) {
+		err = modFile.AddGoStmt(goVersion)
+		if err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	// like `go mod tidy`, we do not keep a toolchain directive that only
+	// repeats the go directive
+	if toolchain != "" && modFile.Go != nil && toolchain == "go"+modFile.Go.Version {
+		toolchain = DefaultToolchain
+	}
+	switch {
+	case toolchain == DefaultToolchain:
+		if modFile.Toolchain != nil {
+			modFile.DropToolchainStmt()
+			changed = true
+		}
+	case toolchain != "" && (modFile.Toolchain == nil || modFile.Toolchain.Name != toolchain):
+		err = modFile.AddToolchainStmt(toolchain)
+		if err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	// if nothing changed, we do not want to reformat the file
+	if !changed {
+		return buf, nil
+	}
+	modFile.Cleanup()
+	return modFile.Format()
+}
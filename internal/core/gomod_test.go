@@ -0,0 +1,80 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"testing"
+)
+
+const goModBefore = `module github.com/example/foo
+
+// we need go 1.20 for errors.Join
+go 1.20.4
+
+require github.com/lib/pq v1.10.9
+`
+
+func TestUpdateGoMod(t *testing.T) {
+	testCases := []struct {
+		goVersion string
+		toolchain string
+		expected  string
+	}{
+		{
+			// three-part versions and comments must not be mangled
+			goVersion: "1.21",
+			expected:  "module github.com/example/foo\n\n// we need go 1.20 for errors.Join\ngo 1.21\n\nrequire github.com/lib/pq v1.10.9\n",
+		},
+		{
+			goVersion: "1.21",
+			toolchain: "go1.21.4",
+			expected:  "module github.com/example/foo\n\n// we need go 1.20 for errors.Join\ngo 1.21\n\ntoolchain go1.21.4\n\nrequire github.com/lib/pq v1.10.9\n",
+		},
+		{
+			// the toolchain directive is redundant here
+			toolchain: "go1.20.4",
+			expected:  goModBefore,
+		},
+		{
+			// nothing to do
+			expected: goModBefore,
+		},
+	}
+
+	for _, tc := range testCases {
+		actual, err := UpdateGoMod(ModFilename, []byte(goModBefore), tc.goVersion, tc.toolchain)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(actual) != tc.expected {
+			t.Errorf("with goVersion = %q and toolchain = %q, expected:\n%s\nbut got:\n%s", tc.goVersion, tc.toolchain, tc.expected, string(actual))
+		}
+	}
+
+	// removing the toolchain directive
+	withToolchain := "module github.com/example/foo\n\ngo 1.21\n\ntoolchain go1.21.4\n"
+	actual, err := UpdateGoMod(ModFilename, []byte(withToolchain), "", DefaultToolchain)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := "module github.com/example/foo\n\ngo 1.21\n"
+	if string(actual) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, string(actual))
+	}
+}
@@ -0,0 +1,249 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFilename is the file in which go-makefile-maker records which files
+// it has generated.
+const ManifestFilename = "Makefile.maker.manifest.json"
+
+// Manifest is the contents of the manifest file.
+type Manifest struct {
+	// Files maps the path of each generated file to the hash of its contents
+	// (as computed by HashContents).
+	Files map[string]string `json:"files"`
+}
+
+// HashContents returns the hash of a generated file that is recorded in the manifest.
+func HashContents(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ReadManifest reads the manifest file in the given repository root. If
+// there is none, an empty manifest is returned.
+func ReadManifest(root string) (Manifest, error) {
+	result := Manifest{Files: make(map[string]string)}
+	buf, err := os.ReadFile(filepath.Join(root, ManifestFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(buf, &result)
+	if err != nil {
+		return result, fmt.Errorf("cannot parse %s: %w", ManifestFilename, err)
+	}
+	if result.Files == nil {
+		result.Files = make(map[string]string)
+	}
+	// the files in the manifest may be deleted, so they must not point outside of the repository
+	for path := range result.Files {
+		if !isRepoRelativePath(path) {
+			return result, fmt.Errorf("cannot parse %s: %q is not a relative path inside the repository", ManifestFilename, path)
+		}
+	}
+	return result, nil
+}
+
+// isRepoRelativePath returns whether the given path (with forward slashes)
+// refers to a file inside the repository.
+func isRepoRelativePath(path string) bool {
+	if path == "" || filepath.IsAbs(filepath.FromSlash(path)) || strings.HasPrefix(path, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// ManifestSink is an OutputSink that records all files written through it in
+// a manifest, and passes all operations on to another OutputSink. Once all
+// files have been generated, Finish() must be called to remove stale files and
+// write the new manifest.
+//
+// Since operations are passed on immediately, the inner sink should usually be
+// a MemorySink whose contents are only persisted if Finish() does not report
+// any conflicts.
+type ManifestSink struct {
+	inner     OutputSink
+	root      string
+	previous  Manifest
+	current   Manifest
+	removed   map[string]bool
+	warnings  []string
+	conflicts []string
+	// isBootstrap is set if there is no previous manifest, i.e. when this
+	// version of go-makefile-maker runs in the repository for the first time.
+	isBootstrap bool
+}
+
+// NewManifestSink returns a ManifestSink that passes all operations on to the
+// given sink. The root is the repository root on disk, where the previous
+// manifest and the previously generated files are read from.
+func NewManifestSink(inner OutputSink, root string) (*ManifestSink, error) {
+	previous, err := ReadManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(filepath.Join(root, ManifestFilename))
+	return &ManifestSink{
+		inner:       inner,
+		root:        root,
+		previous:    previous,
+		current:     Manifest{Files: make(map[string]string)},
+		removed:     make(map[string]bool),
+		isBootstrap: errors.Is(err, fs.ErrNotExist),
+	}, nil
+}
+
+// WriteFile implements the OutputSink interface.
+func (s *ManifestSink) WriteFile(path string, contents []byte, perm fs.FileMode) error {
+	if reason := s.checkOwnership(path, contents); reason != "" {
+		s.conflicts = append(s.conflicts, fmt.Sprintf("%s: %s", path, reason))
+	}
+	s.current.Files[path] = HashContents(contents)
+	delete(s.removed, path)
+	return s.inner.WriteFile(path, contents, perm)
+}
+
+// checkOwnership checks whether the file at the given path can be overwritten
+// with the given contents without losing anything. If not, the reason is
+// returned.
+func (s *ManifestSink) checkOwnership(path string, contents []byte) string {
+	buf, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(path)))
+	if err != nil || bytes.Equal(buf, contents) {
+		return ""
+	}
+	if expectedHash, exists := s.previous.Files[path]; exists {
+		if HashContents(buf) != expectedHash {
+			return "it has been edited by hand since it was generated"
+		}
+		return ""
+	}
+
+	// If the file is not in the manifest, we can only recognize it by the
+	// header (e.g. if it was generated by an older version). Files in formats
+	// that cannot carry a header (like JSON) cannot be recognized at all.
+	if bytes.Contains(contents, []byte(AutogeneratedHeader)) && bytes.Contains(buf, []byte(AutogeneratedHeader)) {
+		return ""
+	}
+
+	// Older versions did not write a manifest, and wrote some files without
+	// the header. On the first run of this version, we adopt these files
+	// instead of refusing to run at all.
+	if s.isBootstrap && isHeaderlessInOlderVersions(path) {
+		s.warnings = append(s.warnings, fmt.Sprintf("%s: adopting this file because older versions of go-makefile-maker generated it without a header (if it was edited by hand, check the changes before committing)", path))
+		return ""
+	}
+	return "it was not generated by go-makefile-maker"
+}
+
+// isHeaderlessInOlderVersions returns whether versions of go-makefile-maker
+// before the introduction of the manifest generated the file at the given
+// path without AutogeneratedHeader.
+func isHeaderlessInOlderVersions(path string) bool {
+	switch path {
+	case "Dockerfile", ".dockerignore", ".goreleaser.yaml", ".github/renovate.json":
+		return true
+	default:
+		return strings.HasPrefix(path, "testing/")
+	}
+}
+
+// RemoveFile implements the OutputSink interface.
+func (s *ManifestSink) RemoveFile(path string) error {
+	delete(s.current.Files, path)
+	s.removed[path] = true
+	return s.inner.RemoveFile(path)
+}
+
+// Finish removes all files that were listed in the previous manifest, but
+// were not generated this time, and then writes the new manifest.
+//
+// It returns warnings about stale files that were kept because they were
+// edited by hand (and about files that were adopted on the first run), and conflicts for existing files that were overwritten even
+// though they were edited by hand or not generated by us. If there are any
+// conflicts, the caller should not persist the generated files unless the
+// user explicitly asked for it (i.e. with --force).
+func (s *ManifestSink) Finish() (warnings, conflicts []string, err error) {
+	stalePaths := make([]string, 0, len(s.previous.Files))
+	for path := range s.previous.Files {
+		_, generated := s.current.Files[path]
+		if !generated && !s.removed[path] {
+			stalePaths = append(stalePaths, path)
+		}
+	}
+	sort.Strings(stalePaths)
+
+	for _, path := range stalePaths {
+		// we do not want to throw away manual changes
+		if reason := s.checkStaleFile(path); reason != "" {
+			s.warnings = append(s.warnings, fmt.Sprintf("%s is not generated anymore, but is kept because %s", path, reason))
+			continue
+		}
+		err := s.inner.RemoveFile(path)
+		if err != nil {
+			return s.warnings, s.conflicts, err
+		}
+	}
+
+	buf, err := json.MarshalIndent(s.current, "", "  ")
+	if err != nil {
+		return s.warnings, s.conflicts, err
+	}
+	buf = append(buf, '\n')
+	return s.warnings, s.conflicts, s.inner.WriteFile(ManifestFilename, buf, 0o666)
+}
+
+// checkStaleFile checks whether the given file, which was generated
+// previously but not this time, can be removed. If not, the reason is
+// returned.
+func (s *ManifestSink) checkStaleFile(path string) string {
+	buf, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(path)))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// if the file was deleted, there is nothing to lose
+		return ""
+	case err != nil:
+		// e.g. if there is a directory in its place now
+		return fmt.Sprintf("it cannot be read: %s", err.Error())
+	case HashContents(buf) != s.previous.Files[path]:
+		return "it has been edited by hand since it was generated"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,195 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestSinkRemovesStaleFiles(t *testing.T) {
+	root := t.TempDir()
+
+	generate := func(paths ...string) []string {
+		t.Helper()
+		sink, err := NewManifestSink(DiskSink{Root: root}, root)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, path := range paths {
+			err := sink.WriteFile(path, []byte("generated "+path+"\n"), 0o666)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		warnings, conflicts, err := sink.Finish()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(conflicts) > 0 {
+			t.Errorf("unexpected conflicts: %v", conflicts)
+		}
+		return warnings
+	}
+	// first run: generate three files
+	warnings := generate("Makefile", "Dockerfile", ".dockerignore")
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	// edit one of the generated files by hand
+	err := os.WriteFile(filepath.Join(root, ".dockerignore"), []byte("edited\n"), 0o666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// second run: Dockerfile and .dockerignore are not generated anymore
+	warnings = generate("Makefile")
+	expected := ".dockerignore is not generated anymore, but is kept because it has been edited by hand since it was generated"
+	if strings.Join(warnings, "\n") != expected {
+		t.Errorf("expected warning %q, but got %q", expected, warnings)
+	}
+	for path, expectExists := range map[string]bool{"Makefile": true, "Dockerfile": false, ".dockerignore": true} {
+		_, err := os.Stat(filepath.Join(root, path))
+		if exists := err == nil; exists != expectExists {
+			t.Errorf("expected exists(%s) = %t, but got %t", path, expectExists, exists)
+		}
+	}
+
+	manifest, err := ReadManifest(root)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(manifest.Files) != 1 || manifest.Files["Makefile"] != HashContents([]byte("generated Makefile\n")) {
+		t.Errorf("unexpected manifest contents: %#v", manifest.Files)
+	}
+}
+
+func TestManifestSinkReportsConflicts(t *testing.T) {
+	root := t.TempDir()
+	manifest := `{"files":{"Makefile":"` + HashContents([]byte("original\n")) + `"}}`
+	writeFiles(t, root, map[string]string{
+		ManifestFilename: manifest,
+		// edited by hand after it was generated
+		"Makefile": "edited\n",
+		// not in the manifest and without our header, e.g. in a repo that just adopted go-makefile-maker
+		".golangci.yaml": "run:\n  timeout: 3m\n",
+		// not in the manifest, but with our header, e.g. generated by an older version
+		".github/workflows/ci.yaml": AutogeneratedHeader + "\nname: CI\n",
+		// not in the manifest and in a format that cannot carry a header
+		".github/renovate.json": "{}\n",
+	})
+
+	sink, err := NewManifestSink(NewMemorySink(), root)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, path := range []string{"Makefile", ".golangci.yaml", ".github/workflows/ci.yaml"} {
+		_ = sink.WriteFile(path, []byte(AutogeneratedHeader+"\nregenerated\n"), 0o666)
+	}
+	_ = sink.WriteFile(".github/renovate.json", []byte(`{"extends":[]}`+"\n"), 0o666)
+
+	warnings, conflicts, err := sink.Finish()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	expected := []string{
+		"Makefile: it has been edited by hand since it was generated",
+		".golangci.yaml: it was not generated by go-makefile-maker",
+		".github/renovate.json: it was not generated by go-makefile-maker",
+	}
+	if strings.Join(conflicts, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected conflicts:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(conflicts, "\n"))
+	}
+}
+
+func TestManifestSinkOnlyRemovesFilesInsideRepository(t *testing.T) {
+	for _, path := range []string{"..", "../other/Makefile", "/etc/passwd", "build/../../Makefile"} {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			ManifestFilename: `{"files":{"` + path + `":"sha256:0000"}}`,
+		})
+		_, err := NewManifestSink(NewMemorySink(), root)
+		if err == nil {
+			t.Errorf("expected manifest entry %q to be rejected", path)
+		}
+	}
+
+	// a stale path that is a directory now is not removed
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		ManifestFilename:   `{"files":{"testing":"` + HashContents([]byte("generated\n")) + `"}}`,
+		"testing/keep.txt": "keep\n",
+	})
+	sink, err := NewManifestSink(DiskSink{Root: root}, root)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	warnings, _, err := sink.Finish()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "testing is not generated anymore, but is kept because it cannot be read") {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if _, err := os.Stat(filepath.Join(root, "testing", "keep.txt")); err != nil {
+		t.Errorf("expected testing/keep.txt to be kept, but got: %s", err.Error())
+	}
+}
+
+func TestManifestSinkAdoptsFilesOnFirstRun(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		// generated by an older version without a header
+		"Dockerfile":            "FROM golang:1.21-alpine AS builder\n",
+		".github/renovate.json": "{}\n",
+		// these always had a header, so they are still recognized by it
+		".golangci.yaml": "run:\n  timeout: 3m\n",
+	})
+
+	sink, err := NewManifestSink(NewMemorySink(), root)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, path := range []string{"Dockerfile", ".golangci.yaml"} {
+		_ = sink.WriteFile(path, []byte(AutogeneratedHeader+"\nregenerated\n"), 0o666)
+	}
+	_ = sink.WriteFile(".github/renovate.json", []byte(`{"extends":[]}`+"\n"), 0o666)
+
+	warnings, conflicts, err := sink.Finish()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedWarnings := []string{
+		"Dockerfile: adopting this file because older versions of go-makefile-maker generated it without a header (if it was edited by hand, check the changes before committing)",
+		".github/renovate.json: adopting this file because older versions of go-makefile-maker generated it without a header (if it was edited by hand, check the changes before committing)",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(expectedWarnings, "\n") {
+		t.Errorf("expected warnings:\n%s\nbut got:\n%s", strings.Join(expectedWarnings, "\n"), strings.Join(warnings, "\n"))
+	}
+	expectedConflicts := []string{".golangci.yaml: it was not generated by go-makefile-maker"}
+	if strings.Join(conflicts, "\n") != strings.Join(expectedConflicts, "\n") {
+		t.Errorf("expected conflicts:\n%s\nbut got:\n%s", strings.Join(expectedConflicts, "\n"), strings.Join(conflicts, "\n"))
+	}
+}
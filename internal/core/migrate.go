@@ -0,0 +1,330 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keyMigration describes how an obsolete config key is converted into its
+// replacement.
+type keyMigration struct {
+	// Path is the YAML path of the obsolete key, e.g. "goReleaser.enabled".
+	Path string
+	// NewKey replaces the last element of Path in the same map.
+	NewKey string
+	// Convert computes the value for NewKey from the value of the obsolete key.
+	// If it returns nil, the obsolete key is removed without replacement. If
+	// Convert is nil, the value is kept as it is.
+	Convert func(value *yaml.Node) *yaml.Node
+	// Note is shown when the obsolete key is removed without replacement.
+	Note string
+}
+
+// keyMigrations lists all obsolete config keys that `go-makefile-maker migrate`
+// knows about, in the order in which they are applied.
+//
+// NOTE: When renaming or removing a config key, please add a migration here.
+var keyMigrations = []keyMigration{
+	{
+		// the README used to document this key in lowercase
+		Path:   "goreleaser",
+		NewKey: "goReleaser",
+	},
+	{
+		// the README used to document this instead of createConfig
+		Path:   "goReleaser.enabled",
+		NewKey: "createConfig",
+	},
+	{
+		Path:   "dockerfile.user",
+		NewKey: "runAsRoot",
+		Convert: func(value *yaml.Node) *yaml.Node {
+			if value.Value != "root" {
+				return nil
+			}
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
+		},
+		Note: "commands now run as user `appuser` (ID 4200) in group `appgroup` (ID 4200)",
+	},
+}
+
+// migrationForKey returns the migration for the given obsolete key, if any.
+func migrationForKey(path string) (keyMigration, bool) {
+	for _, m := range keyMigrations {
+		if m.Path == path {
+			return m, true
+		}
+	}
+	return keyMigration{}, false
+}
+
+// MigrateConfiguration rewrites all obsolete keys in the given config file
+// into their replacements. It returns the new file contents and a description
+// of each change. Only the affected keys and values are rewritten in place, so
+// everything else (comments, blank lines, alignment, quoting) is preserved.
+// If no changes are made, the original contents are returned unchanged.
+func MigrateConfiguration(buf []byte) (result []byte, changes []string, err error) {
+	var doc yaml.Node
+	err = yaml.Unmarshal(buf, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return buf, nil, nil
+	}
+
+	src := newSourceFile(buf)
+	var edits []textEdit
+	for _, m := range keyMigrations {
+		parentPath, oldKey := "", m.Path
+		if idx := strings.LastIndex(m.Path, "."); idx >= 0 {
+			parentPath, oldKey = m.Path[:idx], m.Path[idx+1:]
+		}
+		newPath := joinPath(parentPath, m.NewKey)
+
+		parent := findMapping(doc.Content[0], parentPath)
+		if parent == nil {
+			continue
+		}
+		oldIdx, newIdx := -1, -1
+		for idx := 0; idx+1 < len(parent.Content); idx += 2 {
+			switch parent.Content[idx].Value {
+			case oldKey:
+				oldIdx = idx
+			case m.NewKey:
+				newIdx = idx
+			}
+		}
+		if oldIdx < 0 {
+			continue
+		}
+		if newIdx >= 0 {
+			changes = append(changes, fmt.Sprintf("could not replace %s with %s because %s is already set, please merge them manually", m.Path, newPath, newPath))
+			continue
+		}
+
+		keyNode, valueNode := parent.Content[oldIdx], parent.Content[oldIdx+1]
+		newValueNode := valueNode
+		if m.Convert != nil {
+			newValueNode = m.Convert(valueNode)
+		}
+		manualMsg := fmt.Sprintf("could not rewrite %s automatically because of how it is written, please migrate it manually", m.Path)
+
+		if newValueNode == nil {
+			edit, ok := src.removeEntry(parent, keyNode, valueNode)
+			if !ok {
+				changes = append(changes, manualMsg)
+				continue
+			}
+			edits = append(edits, edit)
+			parent.Content = append(parent.Content[:oldIdx], parent.Content[oldIdx+2:]...)
+			changes = append(changes, fmt.Sprintf("removed %s: %s (%s)", m.Path, valueNode.Value, m.Note))
+			continue
+		}
+
+		keyEdit, ok := src.replaceScalar(keyNode, m.NewKey, keyNode.Style)
+		if !ok {
+			changes = append(changes, manualMsg)
+			continue
+		}
+		if newValueNode != valueNode {
+			valueEdit, ok := src.replaceScalar(valueNode, newValueNode.Value, newValueNode.Style)
+			if !ok {
+				changes = append(changes, manualMsg)
+				continue
+			}
+			edits = append(edits, valueEdit)
+		}
+		edits = append(edits, keyEdit)
+
+		// later migrations need to see the new key and value
+		keyNode.Value = m.NewKey
+		parent.Content[oldIdx+1] = newValueNode
+		if newValueNode == valueNode {
+			changes = append(changes, fmt.Sprintf("renamed %s to %s", m.Path, newPath))
+		} else {
+			changes = append(changes, fmt.Sprintf("replaced %s: %s with %s: %s", m.Path, valueNode.Value, newPath, newValueNode.Value))
+		}
+	}
+	if len(edits) == 0 {
+		return buf, changes, nil
+	}
+	return applyTextEdits(buf, edits), changes, nil
+}
+
+// textEdit replaces the bytes in [start, end) with the given text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyTextEdits applies the given non-overlapping edits to buf.
+func applyTextEdits(buf []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	offset := 0
+	for _, e := range edits {
+		out.Write(buf[offset:e.start])
+		out.WriteString(e.text)
+		offset = e.end
+	}
+	out.Write(buf[offset:])
+	return out.Bytes()
+}
+
+// sourceFile maps the positions reported by yaml.Node to byte offsets.
+type sourceFile struct {
+	buf        []byte
+	lineStarts []int
+}
+
+func newSourceFile(buf []byte) sourceFile {
+	lineStarts := []int{0}
+	for idx, b := range buf {
+		if b == '\n' {
+			lineStarts = append(lineStarts, idx+1)
+		}
+	}
+	return sourceFile{buf, lineStarts}
+}
+
+// offsetOf returns the byte offset of the start of the given node. (Columns
+// are counted in characters, not in bytes.)
+func (f sourceFile) offsetOf(node *yaml.Node) int {
+	offset := f.lineStarts[node.Line-1]
+	for col := 1; col < node.Column && offset < len(f.buf); col++ {
+		_, size := utf8.DecodeRune(f.buf[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// lineEnd returns the byte offset after the newline that ends the given line.
+func (f sourceFile) lineEnd(line int) int {
+	if line < len(f.lineStarts) {
+		return f.lineStarts[line]
+	}
+	return len(f.buf)
+}
+
+// scalarEnd returns the byte offset of the end of the given scalar, or false
+// if the scalar is written in a way that we do not rewrite (e.g. as a block
+// scalar, with a tag, or across multiple lines).
+func (f sourceFile) scalarEnd(node *yaml.Node) (int, bool) {
+	if node.Kind != yaml.ScalarNode || node.Line == 0 {
+		return 0, false
+	}
+	start := f.offsetOf(node)
+	rest := f.buf[start:f.lineEnd(node.Line)]
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle|yaml.TaggedStyle) != 0:
+		return 0, false
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		// the closing quote must be on the same line; escapes are skipped over
+		// because only the position of the closing quote matters
+		quote := rest[0]
+		for idx := 1; idx < len(rest); idx++ {
+			switch {
+			case quote == '"' && rest[idx] == '\\':
+				idx++
+			case quote == '\'' && rest[idx] == '\'' && idx+1 < len(rest) && rest[idx+1] == '\'':
+				idx++
+			case rest[idx] == quote:
+				return start + idx + 1, true
+			}
+		}
+		return 0, false
+	default:
+		if !bytes.HasPrefix(rest, []byte(node.Value)) {
+			return 0, false
+		}
+		return start + len(node.Value), true
+	}
+}
+
+// replaceScalar returns an edit that replaces the given scalar with the given
+// value, using the quoting style from the given style. (Keys keep their
+// quoting style, but new values bring their own, e.g. a bool must not be
+// quoted even if the string that it replaces was.)
+func (f sourceFile) replaceScalar(node *yaml.Node, value string, style yaml.Style) (textEdit, bool) {
+	end, ok := f.scalarEnd(node)
+	if !ok {
+		return textEdit{}, false
+	}
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		value = strconv.Quote(value)
+	case style&yaml.SingleQuotedStyle != 0:
+		value = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return textEdit{f.offsetOf(node), end, value}, true
+}
+
+// removeEntry returns an edit that removes the lines containing the given
+// entry of a block mapping. This only works if the entry is on lines of its
+// own, and if its value is a single-line scalar.
+func (f sourceFile) removeEntry(parent, keyNode, valueNode *yaml.Node) (textEdit, bool) {
+	if parent.Style&yaml.FlowStyle != 0 || keyNode.Line != valueNode.Line {
+		return textEdit{}, false
+	}
+	if _, ok := f.scalarEnd(valueNode); !ok {
+		return textEdit{}, false
+	}
+	start := f.lineStarts[keyNode.Line-1]
+	if len(bytes.TrimSpace(f.buf[start:f.offsetOf(keyNode)])) > 0 {
+		// e.g. "- user: root" in a list item
+		return textEdit{}, false
+	}
+	return textEdit{start, f.lineEnd(keyNode.Line), ""}, true
+}
+
+// findMapping returns the mapping node at the given dot-separated path, or
+// nil if there is none.
+func findMapping(root *yaml.Node, path string) *yaml.Node {
+	current := root
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var next *yaml.Node
+			if current.Kind == yaml.MappingNode {
+				for idx := 0; idx+1 < len(current.Content); idx += 2 {
+					if current.Content[idx].Value == key {
+						next = current.Content[idx+1]
+						break
+					}
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			current = next
+		}
+	}
+	if current.Kind != yaml.MappingNode {
+		return nil
+	}
+	return current
+}
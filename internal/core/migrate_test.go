@@ -0,0 +1,123 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const obsoleteConfig = `# header comment
+metadata:
+  url: https://github.com/example/foo
+
+binaries:
+  - name:        foo
+    fromPackage: .
+
+dockerfile:
+  enabled: true
+  user:    "root" # needs to bind port 80
+
+# release config
+"goreleaser":
+  enabled: true # in the same file
+`
+
+const migratedConfig = `# header comment
+metadata:
+  url: https://github.com/example/foo
+
+binaries:
+  - name:        foo
+    fromPackage: .
+
+dockerfile:
+  enabled: true
+  runAsRoot:    true # needs to bind port 80
+
+# release config
+"goReleaser":
+  createConfig: true # in the same file
+`
+
+func TestMigrateConfiguration(t *testing.T) {
+	result, changes, err := MigrateConfiguration([]byte(obsoleteConfig))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(result) != migratedConfig {
+		t.Errorf("expected migrated config:\n%s\nbut got:\n%s", migratedConfig, string(result))
+	}
+
+	expectedChanges := []string{
+		"renamed goreleaser to goReleaser",
+		"renamed goReleaser.enabled to goReleaser.createConfig",
+		"replaced dockerfile.user: root with dockerfile.runAsRoot: true",
+	}
+	if strings.Join(changes, "\n") != strings.Join(expectedChanges, "\n") {
+		t.Errorf("expected changes:\n%s\nbut got:\n%s", strings.Join(expectedChanges, "\n"), strings.Join(changes, "\n"))
+	}
+
+	// the migrated config must be valid, and migrating it again must be a no-op
+	_, issues := ParseConfiguration(result)
+	for _, issue := range issues {
+		t.Errorf("unexpected issue in migrated config: %s", issue.Format(ConfigFilename))
+	}
+	again, changes, err := MigrateConfiguration(result)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(changes) > 0 || string(again) != string(result) {
+		t.Errorf("expected second migration to be a no-op, but got changes: %v", changes)
+	}
+}
+
+func TestMigrateConfigurationRemovesObsoleteUser(t *testing.T) {
+	input := "dockerfile:\n  enabled: true\n  user: nobody\n  runAsRoot: false\n"
+	_, changes, err := MigrateConfiguration([]byte(input))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// runAsRoot is already set, so we must not touch anything
+	expected := "could not replace dockerfile.user with dockerfile.runAsRoot because dockerfile.runAsRoot is already set, please merge them manually"
+	if strings.Join(changes, "\n") != expected {
+		t.Errorf("expected %q, but got %q", expected, changes)
+	}
+
+	input = "dockerfile:\n  enabled: true\n  user: nobody # comment\n\n  extraPackages: [ curl ]\n"
+	result, _, err := MigrateConfiguration([]byte(input))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(result) != "dockerfile:\n  enabled: true\n\n  extraPackages: [ curl ]\n" {
+		t.Errorf("expected dockerfile.user to be removed, but got:\n%s", string(result))
+	}
+
+	// we do not try to rewrite flow mappings line by line
+	input = "dockerfile: { enabled: true, user: nobody }\n"
+	result, changes, err = MigrateConfiguration([]byte(input))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = "could not rewrite dockerfile.user automatically because of how it is written, please migrate it manually"
+	if string(result) != input || strings.Join(changes, "\n") != expected {
+		t.Errorf("expected %q with unchanged input, but got %q with:\n%s", expected, changes, string(result))
+	}
+}
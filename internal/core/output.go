@@ -0,0 +1,142 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// OutputSink is where the renderers put the files that they generate. All
+// paths are relative to the repository root and use forward slashes.
+type OutputSink interface {
+	// WriteFile writes a generated file, creating its parent directories if
+	// necessary.
+	WriteFile(path string, contents []byte, perm fs.FileMode) error
+	// RemoveFile removes a file that was generated by a previous version of
+	// go-makefile-maker, or by a feature that has since been disabled. It is
+	// not an error if the file does not exist.
+	RemoveFile(path string) error
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// DiskSink
+
+// DiskSink is an OutputSink that writes into a directory on disk.
+type DiskSink struct {
+	// Root is the repository root. If empty, the current working directory is used.
+	Root string
+}
+
+// WriteFile implements the OutputSink interface.
+func (d DiskSink) WriteFile(path string, contents []byte, perm fs.FileMode) error {
+	fullPath := d.fullPath(path)
+	err := os.MkdirAll(filepath.Dir(fullPath), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, contents, perm)
+}
+
+// RemoveFile implements the OutputSink interface.
+func (d DiskSink) RemoveFile(path string) error {
+	// not os.RemoveAll(): we only ever generate files, so a directory at this
+	// path is not ours to delete
+	err := os.Remove(d.fullPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d DiskSink) fullPath(path string) string {
+	return filepath.Join(d.Root, filepath.FromSlash(path))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// MemorySink
+
+// PendingFile is a file operation recorded by a MemorySink.
+type PendingFile struct {
+	Path     string
+	Contents []byte
+	Perm     fs.FileMode
+	Deleted  bool
+}
+
+// MemorySink is an OutputSink that records all operations in memory instead
+// of executing them. This is used to compare the generated files with the
+// files on disk, and to inspect generated files in tests.
+type MemorySink struct {
+	files map[string]PendingFile
+}
+
+// NewMemorySink returns an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: make(map[string]PendingFile)}
+}
+
+// WriteFile implements the OutputSink interface.
+func (m *MemorySink) WriteFile(path string, contents []byte, perm fs.FileMode) error {
+	m.files[path] = PendingFile{Path: path, Contents: contents, Perm: perm}
+	return nil
+}
+
+// RemoveFile implements the OutputSink interface.
+func (m *MemorySink) RemoveFile(path string) error {
+	m.files[path] = PendingFile{Path: path, Deleted: true}
+	return nil
+}
+
+// File returns the last operation recorded for the given path, if any.
+func (m *MemorySink) File(path string) (PendingFile, bool) {
+	f, exists := m.files[path]
+	return f, exists
+}
+
+// Files returns the last operation recorded for each path, sorted by path.
+func (m *MemorySink) Files() []PendingFile {
+	result := make([]PendingFile, 0, len(m.files))
+	for _, f := range m.files {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// ApplyTo executes all operations recorded in this sink on the given sink.
+func (m *MemorySink) ApplyTo(sink OutputSink) error {
+	for _, f := range m.Files() {
+		var err error
+		if f.Deleted {
+			err = sink.RemoveFile(f.Path)
+		} else {
+			err = sink.WriteFile(f.Path, f.Contents, f.Perm)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskSink(t *testing.T) {
+	root := t.TempDir()
+	sink := DiskSink{Root: root}
+
+	err := sink.WriteFile(".github/workflows/ci.yaml", []byte("name: CI\n"), 0o666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	buf, err := os.ReadFile(filepath.Join(root, ".github", "workflows", "ci.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(buf) != "name: CI\n" {
+		t.Errorf("unexpected file contents: %q", string(buf))
+	}
+
+	for i := 0; i < 2; i++ { // removing a nonexistent file is not an error
+		err = sink.RemoveFile(".github/workflows/ci.yaml")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	_, err = os.Stat(filepath.Join(root, ".github", "workflows", "ci.yaml"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected file to be removed, but got err = %v", err)
+	}
+}
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	_ = sink.WriteFile("b", []byte("first"), 0o666)
+	_ = sink.WriteFile("b", []byte("second"), 0o666)
+	_ = sink.RemoveFile("a")
+
+	files := sink.Files()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	if files[0].Path != "a" || !files[0].Deleted {
+		t.Errorf("expected deletion of a, got %#v", files[0])
+	}
+	if files[1].Path != "b" || string(files[1].Contents) != "second" {
+		t.Errorf("expected last write of b to win, got %#v", files[1])
+	}
+}
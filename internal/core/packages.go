@@ -0,0 +1,174 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+	"golang.org/x/mod/module"
+)
+
+// DetectBinaries fills c.Binaries with all main packages in the modules of the
+// repository at the given root directory if the config file contains `binaries: auto`.
+// Otherwise, it does nothing.
+func (c *Configuration) DetectBinaries(root string, sr ScanResult) error {
+	if !c.AutoDetectBinaries {
+		return nil
+	}
+	installTo := c.AutoBinaries.InstallTo
+	if installTo == "" {
+		installTo = "bin/"
+	}
+
+	pkgs, err := FindMainPackages(root, sr.Modules)
+	if err != nil {
+		return err
+	}
+	c.Binaries = nil
+	pkgsByName := make(map[string]string)
+	for _, pkg := range pkgs {
+		if isExcludedPackage(pkg, c.AutoBinaries.Exclude) {
+			continue
+		}
+
+		name := path.Base(pkg)
+		if m := sr.ModuleForPackage(pkg); m.Dir == pkg {
+			// for "github.com/foo/bar/v2", we want "bar" instead of "v2"
+			prefix, _, _ := module.SplitPathVersion(m.Path)
+			name = path.Base(prefix)
+		}
+		if other, exists := pkgsByName[name]; exists {
+			return fmt.Errorf("binaries: auto: both %s and %s would be built into build/%s, please add one of them to autoBinaries.exclude", other, pkg, name)
+		}
+		pkgsByName[name] = pkg
+
+		c.Binaries = append(c.Binaries, BinaryConfiguration{
+			Name:        name,
+			FromPackage: pkg,
+			InstallTo:   installTo,
+		})
+	}
+
+	if len(c.Binaries) == 0 {
+		return errors.New("binaries: auto: could not find any main packages")
+	}
+	return nil
+}
+
+// isExcludedPackage checks whether the given package path (e.g. "./cmd/foo")
+// matches one of the given patterns. A pattern matches either exactly, or,
+// if it ends in "/...", also all packages below it.
+func isExcludedPackage(pkg string, patterns []string) bool {
+	for _, pattern := range patterns {
+		prefix, isWildcard := strings.CutSuffix(pattern, "/...")
+		prefix = cleanPackagePath(prefix)
+		if pkg == prefix || (isWildcard && (prefix == "." || strings.HasPrefix(pkg, prefix+"/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPackagePath brings a relative package or module path into the form
+// returned by FindMainPackages, e.g. "cmd/foo/" -> "./cmd/foo".
+func cleanPackagePath(pkg string) string {
+	pkg = path.Clean(pkg)
+	if pkg == "." || strings.HasPrefix(pkg, "../") || path.IsAbs(pkg) {
+		return pkg
+	}
+	return "./" + pkg
+}
+
+// FindMainPackages returns the relative paths (e.g. "." or "./cmd/foo") of
+// all directories in the given modules of the repository at root that contain
+// a main package. Like the go command, it skips vendor and testdata
+// directories, directories starting with "." or "_", and nested modules that
+// are not in the list. Directories that the go command would also not accept
+// as a single package (no buildable Go files, or files from multiple
+// packages) are skipped.
+func FindMainPackages(root string, modules []ModuleInfo) ([]string, error) {
+	var result []string
+	for _, m := range modules {
+		moduleRoot := filepath.Join(root, filepath.FromSlash(m.Dir))
+		err := filepath.WalkDir(moduleRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != moduleRoot {
+				if isIgnoredDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				// nested modules are walked separately if they are part of the repository's modules
+				if fileExists(filepath.Join(path, ModFilename)) {
+					return filepath.SkipDir
+				}
+			}
+
+			pkg, err := build.Default.ImportDir(path, 0)
+			var (
+				noGoErr        *build.NoGoError
+				multiplePkgErr *build.MultiplePackageError
+			)
+			switch {
+			case errors.As(err, &noGoErr):
+				return nil
+			case errors.As(err, &multiplePkgErr):
+				logg.Other("WARNING", "cannot check %s for a main package: %s", path, err.Error())
+				return nil
+			case err != nil:
+				return err
+			case pkg.Name != "main":
+				return nil
+			}
+
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			result = append(result, cleanPackagePath(filepath.ToSlash(relPath)))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// isIgnoredDir returns whether the go command ignores directories with this
+// name when matching "./...".
+func isIgnoredDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
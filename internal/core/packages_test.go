@@ -0,0 +1,55 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectBinariesInAllModules(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":                     "module github.com/example/foo\n\ngo 1.21\n",
+		"cmd/foo/main.go":            "package main\n",
+		"internal/mixed/a.go":        "package a\n",
+		"internal/mixed/b.go":        "package main\n",
+		"internal/empty/README.md":   "nothing to see here\n",
+		"api/go.mod":                 "module github.com/example/foo/api/v2\n\ngo 1.21\n",
+		"api/main.go":                "package main\n",
+		"api/cmd/api-client/main.go": "package main\n",
+		"testdata/fixture/main.go":   "package main\n",
+	})
+
+	sr := Scan(root, true)
+	cfg := Configuration{AutoDetectBinaries: true}
+	err := cfg.DetectBinaries(root, sr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []BinaryConfiguration{
+		{Name: "foo", FromPackage: "./cmd/foo", InstallTo: "bin/"},
+		{Name: "api", FromPackage: "./api", InstallTo: "bin/"},
+		{Name: "api-client", FromPackage: "./api/cmd/api-client", InstallTo: "bin/"},
+	}
+	if !reflect.DeepEqual(cfg.Binaries, expected) {
+		t.Errorf("expected binaries %#v, but got %#v", expected, cfg.Binaries)
+	}
+}
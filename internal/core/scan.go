@@ -19,7 +19,13 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
@@ -29,46 +35,233 @@ import (
 )
 
 // ScanResult contains data obtained through a scan of the configuration files
-// in the repository. At the moment, only `go.mod` is scanned.
+// in the repository. At the moment, `go.mod`, `go.work`, the action lock file,
+// the imports of all Go files (to detect cgo usage) and the fuzz tests in all
+// test files are scanned.
 type ScanResult struct {
 	ModulePath           string           // from "module" directive in go.mod, e.g. "github.com/foo/bar"
 	GoVersion            string           // from "go" directive in go.mod, e.g. "1.17"
-	GoDirectDependencies []module.Version // from "require" directive(s) in go.mod without the "// indirect" comment
+	Toolchain            string           // from "toolchain" directive in go.mod, e.g. "go1.21.4" (empty if there is none)
+	GoDirectDependencies []module.Version // from "require" directive(s) in all go.mod files without the "// indirect" comment
 	HasBinInfo           bool             // whether we can produce linker instructions for "github.com/sapcc/go-api-declarations/bininfo"
-	UsesPostgres         bool             // wether postgres is used
+	TestServices         []string         // names of the services from TestServiceCatalog that the direct dependencies in all go.mod files need (see also TestService.IndirectModules)
+	UsesCGO              bool             // whether any package has `import "C"`, or any go.mod file requires a library that requires cgo (even indirectly)
+	Modules              []ModuleInfo     // all modules in the repository, the main module first
+	HasGoWork            bool             // whether there is a go.work file
+	FuzzTargets          []FuzzTarget     // all `func FuzzXxx(f *testing.F)` in test files
+	ActionLock           ActionLock       // from Makefile.maker.lock.json, see `githubWorkflow.pinActions`
 }
 
-const ModFilename = "go.mod"
+// ModuleInfo describes one of the Go modules in a repository.
+type ModuleInfo struct {
+	Dir  string // relative path of the module directory, e.g. "." or "./api"
+	Path string // from "module" directive in the module's go.mod, e.g. "github.com/foo/bar/api"
+}
+
+const (
+	ModFilename  = "go.mod"
+	WorkFilename = "go.work"
+)
+
+// Scan scans the repository in the given directory.
+//
+// If there is a go.work file, the modules listed in its "use" directives are
+// considered. Otherwise, the module in the root directory is considered, and
+// if withNestedModules is true, all modules below it as well. The module in
+// the root directory is the main module. If there is none, the first module
+// listed in go.work is used.
+func Scan(root string, withNestedModules bool) ScanResult {
+	var result ScanResult
+	moduleDirs, hasGoWork, err := findModuleDirs(root, withNestedModules)
+	if err != nil {
+		logg.Fatal(err.Error())
+	}
+	result.HasGoWork = hasGoWork
+
+	var requiredModules, allRequiredModules []string // the former only contains direct dependencies
+	for idx, dir := range moduleDirs {
+		modFilePath := filepath.Join(root, filepath.FromSlash(dir), ModFilename)
+		modFileBytes := must.Return(os.ReadFile(modFilePath))
+		modFile := must.Return(modfile.Parse(modFilePath, modFileBytes, nil))
+		result.Modules = append(result.Modules, ModuleInfo{
+			Dir:  dir,
+			Path: modFile.Module.Mod.Path,
+		})
 
-func Scan() ScanResult {
-	modFileBytes := must.Return(os.ReadFile(ModFilename))
-	modFile := must.Return(modfile.Parse(ModFilename, modFileBytes, nil))
+		isMainModule := idx == 0
+		if isMainModule {
+			result.ModulePath = modFile.Module.Mod.Path
+			result.setGoDirectives(modFile)
+		}
 
-	var goDeps []module.Version
-	hasBinInfo := false
-	usesPostgres := false
+		for _, v := range modFile.Require {
+			if !v.Indirect {
+				result.GoDirectDependencies = append(result.GoDirectDependencies, v.Mod)
+				// test services are only provided for libraries that the code uses
+				// itself, not for those that e.g. an SDK pulls in
+				requiredModules = append(requiredModules, v.Mod.Path)
+			}
+			if v.Mod.Path == "github.com/sapcc/go-api-declarations" && isMainModule {
+				if semver.Compare(v.Mod.Version, "v1.2.0") >= 0 {
+					result.HasBinInfo = true
+				}
+			}
+			allRequiredModules = append(allRequiredModules, v.Mod.Path)
+		}
+	}
 
-	for _, v := range modFile.Require {
-		if !v.Indirect {
-			goDeps = append(goDeps, v.Mod)
+	for _, s := range TestServiceCatalog {
+		if slices.ContainsFunc(requiredModules, s.matchesModule) || slices.ContainsFunc(allRequiredModules, s.matchesIndirectModule) {
+			result.TestServices = append(result.TestServices, s.Name)
 		}
-		if v.Mod.Path == "github.com/sapcc/go-api-declarations" {
-			if semver.Compare(v.Mod.Version, "v1.2.0") >= 0 {
-				hasBinInfo = true
+	}
+
+	// libraries that require cgo are usually pulled in by other libraries, so
+	// indirect dependencies are considered as well
+	result.UsesCGO = must.Return(detectCGO(root, moduleDirs)) || slices.ContainsFunc(allRequiredModules, isCGOImport)
+	result.FuzzTargets = must.Return(detectFuzzTargets(root, result.Modules))
+	result.ActionLock = must.Return(ReadActionLock(root))
+	return result
+}
+
+func (sr *ScanResult) setGoDirectives(modFile *modfile.File) {
+	sr.GoVersion, sr.Toolchain = "", ""
+	if modFile.Go != nil {
+		sr.GoVersion = modFile.Go.Version
+	}
+	if modFile.Toolchain != nil {
+		sr.Toolchain = modFile.Toolchain.Name
+	}
+}
+
+// UpdateGoDirectives takes the "go" and "toolchain" directives from the given
+// contents of the main module's go.mod file. This is used when go.mod is
+// rewritten after Scan() has already read it (or, in check mode, when the
+// rewritten file is not written to disk).
+func (sr *ScanResult) UpdateGoDirectives(modFileBytes []byte) error {
+	modFile, err := modfile.Parse(ModFilename, modFileBytes, nil)
+	if err != nil {
+		return err
+	}
+	sr.setGoDirectives(modFile)
+	return nil
+}
+
+// GoToolchainVersion returns the exact Go version that shall be used for
+// building, e.g. "1.21.4". This is the version from the toolchain directive
+// if there is one, or the version from the go directive otherwise.
+func (sr ScanResult) GoToolchainVersion() string {
+	if sr.Toolchain != "" {
+		return strings.TrimPrefix(sr.Toolchain, "go")
+	}
+	return sr.GoVersion
+}
+
+// UsesTestService returns whether the dependencies need the test service with the given name.
+func (sr ScanResult) UsesTestService(name string) bool {
+	return slices.Contains(sr.TestServices, name)
+}
+
+// IsMultiModule returns whether the repository contains more than one module.
+func (sr ScanResult) IsMultiModule() bool {
+	return len(sr.Modules) > 1
+}
+
+// ModuleForPackage returns the module containing the given package path
+// (e.g. "./api/cmd/foo"), i.e. the module with the longest matching directory.
+func (sr ScanResult) ModuleForPackage(pkg string) ModuleInfo {
+	pkg = cleanPackagePath(pkg)
+	var result ModuleInfo
+	for _, m := range sr.Modules {
+		if m.Dir == "." || pkg == m.Dir || strings.HasPrefix(pkg, m.Dir+"/") {
+			if result.Dir == "" || len(m.Dir) > len(result.Dir) {
+				result = m
 			}
 		}
-		if v.Mod.Path == "github.com/lib/pq" {
-			usesPostgres = true
+	}
+	return result
+}
+
+// RelativePackagePath returns the given package path (e.g. "./api/cmd/foo")
+// relative to the module directory (e.g. "./cmd/foo" for the module in
+// "./api"). This is the path that `go build` expects when it runs in the
+// module directory.
+func (m ModuleInfo) RelativePackagePath(pkg string) string {
+	pkg = cleanPackagePath(pkg)
+	if m.Dir == "." || m.Dir == "" {
+		return pkg
+	}
+	if pkg == m.Dir {
+		return "."
+	}
+	return "./" + strings.TrimPrefix(pkg, m.Dir+"/")
+}
+
+// findModuleDirs returns the directories of the modules that Scan() considers
+// (see there), with the main module first, and whether they are listed in a
+// go.work file.
+func findModuleDirs(root string, withNestedModules bool) (moduleDirs []string, hasGoWork bool, err error) {
+	workFileBytes, err := os.ReadFile(filepath.Join(root, WorkFilename))
+	switch {
+	case err == nil:
+		workFile, err := modfile.ParseWork(WorkFilename, workFileBytes, nil)
+		if err != nil {
+			return nil, true, err
+		}
+		for _, use := range workFile.Use {
+			moduleDirs = append(moduleDirs, cleanPackagePath(use.Path))
+		}
+		if len(moduleDirs) == 0 {
+			return nil, true, fmt.Errorf("%s does not contain any use directives", WorkFilename)
+		}
+		hasGoWork = true
+	case errors.Is(err, fs.ErrNotExist):
+		moduleDirs = []string{"."}
+		if withNestedModules {
+			nestedDirs, err := findNestedModules(root)
+			if err != nil {
+				return nil, false, err
+			}
+			moduleDirs = append(moduleDirs, nestedDirs...)
 		}
+	default:
+		return nil, false, err
 	}
 
-	return ScanResult{
-		GoVersion:            modFile.Go.Version,
-		ModulePath:           modFile.Module.Mod.Path,
-		GoDirectDependencies: goDeps,
-		HasBinInfo:           hasBinInfo,
-		UsesPostgres:         usesPostgres,
+	// the main module goes first
+	for idx, dir := range moduleDirs {
+		if dir == "." {
+			moduleDirs[0], moduleDirs[idx] = moduleDirs[idx], moduleDirs[0]
+			break
+		}
 	}
+	return moduleDirs, hasGoWork, nil
+}
+
+// findNestedModules returns the directories of all modules below the given
+// root directory, skipping the same directories as FindMainPackages.
+func findNestedModules(root string) ([]string, error) {
+	var result []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		if isIgnoredDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		if fileExists(filepath.Join(path, ModFilename)) {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			result = append(result, cleanPackagePath(filepath.ToSlash(relPath)))
+		}
+		return nil
+	})
+	return result, err
 }
 
 // MustModulePath reads the ModulePath field, but fails if it is empty.
@@ -0,0 +1,207 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanFindsNestedModules(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                   "module github.com/example/foo\n\ngo 1.21\n",
+		"api/go.mod":               "module github.com/example/foo/api\n\ngo 1.21\n\nrequire github.com/lib/pq v1.10.9\n",
+		"vendor/example/go.mod":    "module github.com/example/vendored\n",
+		"testdata/fixture/go.mod":  "module github.com/example/fixture\n",
+		"tools/generator/go.mod":   "module github.com/example/foo/tools/generator\n\ngo 1.21\n",
+		"tools/generator/.keep.go": "",
+	}
+	writeFiles(t, root, files)
+
+	// nested modules are only considered if requested
+	sr := Scan(root, false)
+	expected := []ModuleInfo{{Dir: ".", Path: "github.com/example/foo"}}
+	if !reflect.DeepEqual(sr.Modules, expected) {
+		t.Errorf("expected modules %#v, but got %#v", expected, sr.Modules)
+	}
+
+	sr = Scan(root, true)
+	expected = []ModuleInfo{
+		{Dir: ".", Path: "github.com/example/foo"},
+		{Dir: "./api", Path: "github.com/example/foo/api"},
+		{Dir: "./tools/generator", Path: "github.com/example/foo/tools/generator"},
+	}
+	if !reflect.DeepEqual(sr.Modules, expected) {
+		t.Errorf("expected modules %#v, but got %#v", expected, sr.Modules)
+	}
+	if sr.ModulePath != "github.com/example/foo" || sr.HasGoWork || !sr.UsesTestService("postgres") {
+		t.Errorf("unexpected scan result: %#v", sr)
+	}
+
+	for pkg, expected := range map[string][2]string{
+		"./cmd/foo":           {".", "./cmd/foo"},
+		"./api":               {"./api", "."},
+		"api/client":          {"./api", "./client"},
+		"./apiserver":         {".", "./apiserver"},
+		"./tools/generator/x": {"./tools/generator", "./x"},
+	} {
+		m := sr.ModuleForPackage(pkg)
+		if m.Dir != expected[0] {
+			t.Errorf("expected package %s to be in module %s, but got %s", pkg, expected[0], m.Dir)
+		}
+		if actual := m.RelativePackagePath(pkg); actual != expected[1] {
+			t.Errorf("expected package %s to be %s within its module, but got %s", pkg, expected[1], actual)
+		}
+	}
+}
+
+func TestScanDetectsCGO(t *testing.T) {
+	testCases := map[string]bool{
+		"package foo\n":                 false,
+		"package foo\n\nimport \"C\"\n": true,
+		"package foo\n\nimport _ \"github.com/mattn/go-sqlite3\"\n":      true,
+		"package foo\n\nimport \"github.com/mattn/go-sqlite3-extras\"\n": false,
+	}
+	for contents, expected := range testCases {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			"go.mod":                  "module github.com/example/foo\n\ngo 1.21\n",
+			"internal/db/db_test.go":  contents,
+			"vendor/example/cgo.go":   "package example\n\nimport \"C\"\n",
+			"testdata/fixture/cgo.go": "package fixture\n\nimport \"C\"\n",
+			"internal/db/_ignored.go": "package db\n\nimport \"C\"\n",
+		})
+
+		sr := Scan(root, false)
+		if sr.UsesCGO != expected {
+			t.Errorf("expected UsesCGO = %t for %q, but got %t", expected, contents, sr.UsesCGO)
+		}
+
+		// the detection can be overridden in the config
+		override := !expected
+		if (GolangConfiguration{EnableCGO: &override}).UsesCGO(sr) != override {
+			t.Errorf("golang.enableCGO = %t was not respected for %q", override, contents)
+		}
+	}
+}
+
+func TestScanDetectsCGOInDependencies(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		// e.g. gorm.io/driver/sqlite pulls in github.com/mattn/go-sqlite3
+		"go.mod":  "module github.com/example/foo\n\ngo 1.21\n\nrequire (\n\tgorm.io/driver/sqlite v1.5.4\n\tgithub.com/mattn/go-sqlite3 v1.14.17 // indirect\n)\n",
+		"main.go": "package main\n\nimport _ \"gorm.io/driver/sqlite\"\n",
+		// files that cannot be parsed are skipped instead of failing the scan
+		"broken.go":      "package main\n\nimport (\n",
+		"broken_test.go": "package main\n\nfunc FuzzBroken(f *testing.F) {\n",
+	})
+
+	sr := Scan(root, false)
+	if !sr.UsesCGO {
+		t.Error("expected cgo usage to be detected from the indirect dependency on github.com/mattn/go-sqlite3")
+	}
+	if len(sr.FuzzTargets) > 0 {
+		t.Errorf("expected no fuzz targets, but got %#v", sr.FuzzTargets)
+	}
+}
+
+func TestScanDetectsFuzzTargets(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod": "module github.com/example/foo\n\ngo 1.21\n",
+		"foo_test.go": "package foo\n\nimport \"testing\"\n\n" +
+			"func Fuzz(f *testing.F) {}\n" +
+			"func FuzzParse(f *testing.F) {}\n" +
+			"func Fuzzy(f *testing.F) {}\n" +
+			"func FuzzTest(t *testing.T) {}\n",
+		"internal/codec/codec_test.go": "package codec\n\nimport tt \"testing\"\n\n" +
+			"type fuzzer struct{}\n\n" +
+			"func (fuzzer) FuzzMethod(f *tt.F) {}\n" +
+			"func FuzzDecode(f *tt.F) {}\n",
+		"internal/codec/codec.go":          "package codec\n\nimport \"testing\"\n\nfunc FuzzHelper(f *testing.F) {}\n",
+		"testdata/fixture/fixture_test.go": "package fixture\n\nimport \"testing\"\n\nfunc FuzzFixture(f *testing.F) {}\n",
+		"api/go.mod":                       "module github.com/example/foo/api\n\ngo 1.21\n",
+		"api/api_test.go":                  "package api\n\nimport \"testing\"\n\nfunc FuzzRequest(f *testing.F) {}\n",
+	})
+
+	sr := Scan(root, true)
+	mainModule := ModuleInfo{Dir: ".", Path: "github.com/example/foo"}
+	apiModule := ModuleInfo{Dir: "./api", Path: "github.com/example/foo/api"}
+	expected := []FuzzTarget{
+		{Name: "Fuzz", Module: mainModule, Package: "."},
+		{Name: "FuzzParse", Module: mainModule, Package: "."},
+		{Name: "FuzzDecode", Module: mainModule, Package: "./internal/codec"},
+		{Name: "FuzzRequest", Module: apiModule, Package: "."},
+	}
+	if !reflect.DeepEqual(sr.FuzzTargets, expected) {
+		t.Errorf("expected fuzz targets %#v, but got %#v", expected, sr.FuzzTargets)
+	}
+	if actual := sr.FuzzTargets[2].ImportPath(); actual != "github.com/example/foo/internal/codec" {
+		t.Errorf("unexpected import path: %q", actual)
+	}
+}
+
+func TestScanDetectsTestServices(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module github.com/example/foo\n\ngo 1.21\n\nrequire (\n\tgithub.com/redis/go-redis/v9 v9.3.0\n\tgithub.com/jackc/pgx/v5 v5.5.0\n\tgithub.com/jackc/pgxlisten v0.0.0-20230728233309-2632bad3185a\n)\n",
+		"api/go.mod": "module github.com/example/foo/api\n\ngo 1.21\n\nrequire github.com/lib/pq v1.10.9\n",
+		// indirect dependencies (e.g. pulled in by an SDK) do not count
+		"client/go.mod": "module github.com/example/foo/client\n\ngo 1.21\n\nrequire (\n\tgithub.com/nats-io/nats.go v1.31.0 // indirect\n\tsigs.k8s.io/controller-runtime v0.16.3 // indirect\n)\n",
+	})
+
+	sr := Scan(root, true)
+	expected := []string{"postgres", "redis"}
+	if !reflect.DeepEqual(sr.TestServices, expected) {
+		t.Errorf("expected test services %#v, but got %#v", expected, sr.TestServices)
+	}
+
+	disabled := false
+	for _, tc := range []struct {
+		Config   TestServicesConfig
+		Expected []string
+	}{
+		{TestServicesConfig{}, []string{"postgres", "redis"}},
+		{TestServicesConfig{Exclude: []string{"postgres"}}, []string{"redis"}},
+		{TestServicesConfig{Enabled: &disabled}, nil},
+	} {
+		var actual []string
+		for _, s := range tc.Config.Services(sr) {
+			actual = append(actual, s.Name)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("expected services %#v for %#v, but got %#v", tc.Expected, tc.Config, actual)
+		}
+	}
+}
+
+func TestScanDetectsPostgresFromIndirectLibPQ(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		// e.g. through github.com/sapcc/go-bits/easypg
+		"go.mod": "module github.com/example/foo\n\ngo 1.21\n\nrequire (\n\tgithub.com/sapcc/go-bits v0.0.0-20231213140446-0f4bc0f5b6f5\n\tgithub.com/lib/pq v1.10.9 // indirect\n\tgithub.com/jackc/pgx/v5 v5.5.0 // indirect\n)\n",
+	})
+
+	sr := Scan(root, false)
+	expected := []string{"postgres"}
+	if !reflect.DeepEqual(sr.TestServices, expected) {
+		t.Errorf("expected test services %#v, but got %#v", expected, sr.TestServices)
+	}
+}
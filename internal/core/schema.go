@@ -0,0 +1,311 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaURL is where the JSON schema for Makefile.maker.yaml is published.
+const SchemaURL = "https://raw.githubusercontent.com/sapcc/go-makefile-maker/main/Makefile.maker.schema.json"
+
+// JSONSchema is a subset of the JSON Schema vocabulary (draft 2020-12) that
+// is sufficient for describing type Configuration.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"` // either false or a *JSONSchema
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Const                any                    `json:"const,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	Deprecated           bool                   `json:"deprecated,omitempty"`
+}
+
+// fieldDoc contains the documentation for a single config key.
+type fieldDoc struct {
+	Description string
+	Default     any
+	Deprecated  bool
+	// Alternatives lists other forms that are accepted for this key besides
+	// the one derived from the Go type, e.g. `binaries: auto`.
+	Alternatives []*JSONSchema
+}
+
+// fieldDocs is keyed by the YAML path of each config key. Fields of list
+// items are addressed without an index, e.g. "binaries.name".
+//
+// NOTE: When adding fields to type Configuration or its subtypes, please add
+// documentation here as well. A unit test ensures that no key is left out.
+var fieldDocs = map[string]fieldDoc{
+	"extends": {
+		Description:  "Base config files (relative to this file) that this file is merged on top of. Maps are merged recursively, other values are replaced. Lists tagged with !append are appended to the base list instead, and null removes a key from the base config.",
+		Alternatives: []*JSONSchema{{Type: "string"}},
+	},
+	"verbatim":  {Description: "Makefile snippet that is copied into the generated Makefile mostly verbatim. Rule recipes may be indented with spaces."},
+	"variables": {Description: "Overrides for the default values of Makefile variables used by the generated recipes, e.g. GO_BUILDFLAGS, GO_LDFLAGS or GO_TESTENV."},
+
+	"binaries": {
+		Description:  "Binaries to build. A build/<name> target is generated for each binary. If set to \"auto\", all main packages in the module are built (see autoBinaries).",
+		Alternatives: []*JSONSchema{{Type: "string", Const: "auto"}},
+	},
+	"binaries.name":        {Description: "Name of the binary. It is built into build/<name>."},
+	"binaries.fromPackage": {Description: "Path of the binary's main package, relative to the repository root."},
+	"binaries.installTo":   {Description: "Directory below $(PREFIX) where `make install` puts the binary. If empty, the binary is not installed."},
+	"binaries.buildFlags":  {Description: "Additional flags for `go build` for this binary, e.g. \"-trimpath\"."},
+	"binaries.ldflags":     {Description: "Additional linker flags for this binary, e.g. \"-extldflags=-static\"."},
+	"binaries.tags":        {Description: "Build tags for this binary."},
+	"binaries.env":         {Description: "Environment variables (in the form KEY=value) for building this binary, e.g. \"CGO_ENABLED=0\"."},
+	"binaries.goos":        {Description: "Target operating system for this binary. If empty, the binary is built for the host (or all goreleaser platforms)."},
+	"binaries.goarch":      {Description: "Target architecture for this binary. If empty, the binary is built for the host (or all goreleaser platforms)."},
+
+	"crossCompile": {Description: "Platforms in the form GOOS/GOARCH (e.g. \"linux/arm64\") for which `make build-cross` builds all binaries into build/<os>_<arch>/<name>."},
+
+	"autoBinaries":           {Description: "Options for `binaries: auto`. Binaries are named after the directory of their main package, or after the module for a main package in the repository root."},
+	"autoBinaries.exclude":   {Description: "Main packages that shall not be built, e.g. \"./cmd/dev-helper\". A trailing \"/...\" excludes all packages below that directory."},
+	"autoBinaries.installTo": {Description: "Directory below $(PREFIX) where `make install` puts the detected binaries.", Default: "bin/"},
+
+	"testPackages":        {Description: "Restricts which packages are tested by `go test`."},
+	"testPackages.only":   {Description: "Regex (for `grep -E`) that package names must match to be tested."},
+	"testPackages.except": {Description: "Regex (for `grep -E`) matching package names that shall not be tested."},
+	"coverageTest":        {Description: "Restricts for which packages test coverage is measured."},
+	"coverageTest.only":   {Description: "Regex (for `grep -E`) that package names must match to be included in the coverage report."},
+	"coverageTest.except": {Description: "Regex (for `grep -E`) matching package names that shall be excluded from the coverage report."},
+
+	"goTest":             {Description: "Flags for `go test` in `make build/cover.out` and `make check-race`."},
+	"goTest.parallelism": {Description: "Number of packages that are tested in parallel (`go test -p`).", Default: 1},
+	"goTest.timeout":     {Description: "Timeout for the tests of each package (`go test -timeout`), e.g. \"20m\". If empty, the default of `go test` applies."},
+	"goTest.count":       {Description: "How often each test is run (`go test -count`). Set this to 1 to disable the test cache."},
+	"goTest.shuffle":     {Description: "Shuffling of tests (`go test -shuffle`): \"on\", \"off\" or an integer seed.", Default: "on"},
+	"goTest.race":        {Description: "Whether `make build/cover.out` (and thereby `make check` and the CI workflow) runs the tests with the race detector. `make check-race` is available either way."},
+	"goTest.tags":        {Description: "Build tags for the tests (`go test -tags`)."},
+	"goTest.failFast":    {Description: "Whether to stop after the first failing test (`go test -failfast`)."},
+
+	"testGroups":             {Description: "Named groups of tests (e.g. unit and integration tests). Each group gets a test-<name> target and its own CI job, and their coverage reports are merged into build/cover.out."},
+	"testGroups.name":        {Description: "Name of the test group. Must only contain lowercase letters, digits and dashes."},
+	"testGroups.only":        {Description: "Regex (for `grep -E`) that package names must match to be tested in this group. Applies in addition to testPackages."},
+	"testGroups.except":      {Description: "Regex (for `grep -E`) matching package names that shall not be tested in this group. Applies in addition to testPackages."},
+	"testGroups.tags":        {Description: "Build tags for this group, in addition to goTest.tags."},
+	"testGroups.env":         {Description: "Environment variables (in the form KEY=value) for this group, in addition to GO_TESTENV."},
+	"testGroups.timeout":     {Description: "Timeout for the tests of each package in this group. Overrides goTest.timeout."},
+	"testGroups.services":    {Description: "Which of the detected test services the CI job of this group needs. If not set, all of them are provided."},
+	"testGroups.skipInCheck": {Description: "Whether `make check` skips this group, e.g. for slow integration tests. `make test-<name>` and CI still run it."},
+
+	"benchmarks":          {Description: "Benchmark targets: `make bench` runs the benchmarks into build/bench/current.txt, and `make bench-compare` compares the results against a committed baseline with benchstat."},
+	"benchmarks.enabled":  {Description: "Whether to generate the benchmark targets."},
+	"benchmarks.only":     {Description: "Regex (for `grep -E`) that package names must match to be benchmarked."},
+	"benchmarks.except":   {Description: "Regex (for `grep -E`) matching package names that shall not be benchmarked."},
+	"benchmarks.pattern":  {Description: "Regex selecting the benchmarks to run (`go test -bench`).", Default: "."},
+	"benchmarks.count":    {Description: "How often each benchmark is run (`go test -count`). benchstat needs multiple runs for a meaningful comparison.", Default: 10},
+	"benchmarks.baseline": {Description: "Path of the committed baseline for `make bench-compare`. `make bench-baseline` updates it.", Default: DefaultBenchmarkBaseline},
+
+	"fuzzing":          {Description: "Settings for the fuzz tests (`func FuzzXxx(f *testing.F)`) in the test files. For each of them, a fuzz/<package>/<name> target is generated, and `make fuzz` runs all of them."},
+	"fuzzing.fuzzTime": {Description: "How long each fuzz test runs (`go test -fuzztime`), either a duration or a number of iterations like \"1000x\". Can be overridden with `make fuzz FUZZTIME=...`.", Default: DefaultFuzzTime},
+
+	"golang":                 {Description: "Settings for the Go toolchain."},
+	"golang.enableVendoring": {Description: "Whether all dependencies are vendored. Replaces `make tidy-deps` with `make vendor` and defaults GO_BUILDFLAGS to `-mod vendor`."},
+	"golang.setGoModVersion": {Description: "Whether to update the go directive in go.mod to the latest Go version supported by go-makefile-maker."},
+	"golang.enableCGO":       {Description: "Whether to build with cgo. This determines CGO_ENABLED in the Makefile and the goreleaser config, and whether a C toolchain is installed in the Dockerfile. Defaults to whether any Go file has `import \"C\"` or imports a library that is known to require cgo (e.g. github.com/mattn/go-sqlite3)."},
+	"golang.nestedModules":   {Description: "Whether modules in subdirectories (i.e. directories below the repository root with their own go.mod) are part of the repository, if there is no go.work file."},
+	"golang.toolchain":       {Description: "If set, the toolchain directive in go.mod is set to this Go release (e.g. `go1.21.4`). Set to `default` to remove the toolchain directive."},
+
+	"golangciLint":                  {Description: "Settings for golangci-lint."},
+	"golangciLint.createConfig":     {Description: "Whether to generate a .golangci.yaml config file."},
+	"golangciLint.errcheckExcludes": {Description: "Functions that shall be excluded from the errcheck linter. Requires createConfig."},
+	"golangciLint.skipDirs":         {Description: "Directories that are skipped entirely by golangci-lint. Requires createConfig."},
+
+	"goReleaser":              {Description: "Settings for goreleaser."},
+	"goReleaser.createConfig": {Description: "Whether to generate a .goreleaser.yaml config file."},
+
+	"spellCheck":             {Description: "Settings for misspell."},
+	"spellCheck.ignoreWords": {Description: "Words that shall be ignored by misspell in golangci-lint and in the spell check workflow."},
+
+	"githubWorkflow":                               {Description: "GitHub Actions workflows to generate."},
+	"githubWorkflow.global":                        {Description: "Settings that apply to all workflows."},
+	"githubWorkflow.global.defaultBranch":          {Description: "Branch on which pushes trigger the workflows. Defaults to the HEAD branch of the origin remote as reported by git."},
+	"githubWorkflow.global.goVersion":              {Description: "Go version for jobs that require Go. Defaults to the version from go.mod."},
+	"githubWorkflow.ci":                            {Description: "Workflow that builds, lints and tests the code."},
+	"githubWorkflow.ci.enabled":                    {Description: "Whether to generate the CI workflow."},
+	"githubWorkflow.ci.ignorePaths":                {Description: "Path patterns for which changes do not trigger the workflow."},
+	"githubWorkflow.ci.runOn":                      {Description: "Runners for the build and test jobs. If more than one is given, the tests run on each of them.", Default: []string{DefaultGitHubComRunnerType}},
+	"githubWorkflow.ci.coveralls":                  {Description: "Whether to upload the test coverage report to Coveralls."},
+	"githubWorkflow.ci.benchmarks":                 {Description: "Whether to add a job that runs `make bench-compare` and posts the comparison to the job summary. Requires benchmarks.enabled."},
+	"githubWorkflow.ci.postgres":                   {Description: "PostgreSQL service container for the test job."},
+	"githubWorkflow.ci.postgres.enabled":           {Description: "Whether to add a PostgreSQL service container to the test job."},
+	"githubWorkflow.ci.postgres.version":           {Description: "Image tag of the postgres image.", Default: DefaultPostgresVersion},
+	"githubWorkflow.ci.kubernetesEnvtest":          {Description: "Kubernetes envtest binaries for the test job."},
+	"githubWorkflow.ci.kubernetesEnvtest.enabled":  {Description: "Whether to download the envtest binaries for the test job."},
+	"githubWorkflow.ci.kubernetesEnvtest.version":  {Description: "Version of the envtest binaries, as understood by setup-envtest.", Default: DefaultK8sEnvtestVersion},
+	"githubWorkflow.fuzzing":                       {Description: "Workflow that runs all fuzz tests every night and uploads failing inputs as artifacts."},
+	"githubWorkflow.fuzzing.enabled":               {Description: "Whether to generate the workflow. It is only generated if the test files contain fuzz tests."},
+	"githubWorkflow.fuzzing.fuzzTime":              {Description: "How long each fuzz test runs in the workflow. Defaults to fuzzing.fuzzTime."},
+	"githubWorkflow.license":                       {Description: "Workflow step that checks that all source files have a license header."},
+	"githubWorkflow.license.enabled":               {Description: "Whether to check for license headers."},
+	"githubWorkflow.license.patterns":              {Description: "File patterns to check.", Default: []string{"**/*.go"}},
+	"githubWorkflow.license.ignorePatterns":        {Description: "File patterns to exclude from the check in addition to vendor/**."},
+	"githubWorkflow.pinActions":                    {Description: "Pins all actions in the generated workflows to the commits recorded in Makefile.maker.lock.json by `go-makefile-maker pin-actions`."},
+	"githubWorkflow.pinActions.enabled":            {Description: "Whether to pin actions to commits."},
+	"githubWorkflow.pinActions.strict":             {Description: "Whether to fail instead of using the tag when an action is missing from the lock file."},
+	"githubWorkflow.pushContainerToGhcr":           {Description: "Workflow that builds the Dockerfile and pushes the image to ghcr.io when a tag is pushed."},
+	"githubWorkflow.pushContainerToGhcr.enabled":   {Description: "Whether to generate the workflow."},
+	"githubWorkflow.release":                       {Description: "Workflow that creates a GitHub release with goreleaser when a version tag is pushed."},
+	"githubWorkflow.release.enabled":               {Description: "Whether to generate the workflow. Requires goReleaser.createConfig."},
+	"githubWorkflow.spellCheck":                    {Description: "Workflow step that checks the entire repository for spelling errors with misspell."},
+	"githubWorkflow.spellCheck.enabled":            {Description: "Whether to check for spelling errors."},
+	"githubWorkflow.securityChecks":                {Description: "CodeQL workflow, and workflow steps for dependency review and govulncheck."},
+	"githubWorkflow.securityChecks.enabled":        {Description: "Whether to run the security checks."},
+	"makefile":                                     {Description: "Settings for the Makefile."},
+	"makefile.enabled":                             {Description: "Whether to generate the Makefile.", Default: true},
+	"renovate":                                     {Description: "Settings for RenovateBot."},
+	"renovate.enabled":                             {Description: "Whether to generate a .github/renovate.json config file."},
+	"renovate.assignees":                           {Description: "GitHub handles of the people that Renovate PRs are assigned to."},
+	"renovate.goVersion":                           {Description: "Go version constraint for Renovate. Defaults to the version from go.mod."},
+	"renovate.packageRules":                        {Description: "Additional package rules that are appended to the default ones. See <https://docs.renovatebot.com/configuration-options/#packagerules>."},
+	"renovate.packageRules.excludePackagePatterns": {Description: "Regexes for package names that this rule does not apply to."},
+	"renovate.packageRules.matchPackageNames":      {Description: "Exact package names that this rule applies to."},
+	"renovate.packageRules.matchPackagePatterns":   {Description: "Regexes for package names that this rule applies to."},
+	"renovate.packageRules.matchPackagePrefixes":   {Description: "Package name prefixes that this rule applies to."},
+	"renovate.packageRules.matchUpdateTypes":       {Description: "Update types (e.g. major, minor, patch) that this rule applies to."},
+	"renovate.packageRules.matchDepTypes":          {Description: "Dependency types that this rule applies to."},
+	"renovate.packageRules.matchFiles":             {Description: "Package files that this rule applies to."},
+	"renovate.packageRules.allowedVersions":        {Description: "Version range that updates are restricted to."},
+	"renovate.packageRules.automerge":              {Description: "Whether to merge matching updates automatically."},
+	"renovate.packageRules.enabled":                {Description: "Set to false to disable updates for matching packages."},
+	"renovate.packageRules.groupName":              {Description: "Name of the group that matching updates are combined into."},
+	"dockerfile":                                   {Description: "Settings for the Dockerfile."},
+	"dockerfile.enabled":                           {Description: "Whether to generate a Dockerfile and a .dockerignore file."},
+	"dockerfile.entrypoint":                        {Description: "Entrypoint of the image. Defaults to the first binary."},
+	"dockerfile.extraDirectives":                   {Description: "Directives that are appended near the end of the Dockerfile."},
+	"dockerfile.extraIgnores":                      {Description: "Entries that are appended to the .dockerignore file."},
+	"dockerfile.extraPackages":                     {Description: "Alpine packages that are installed into the final image in addition to ca-certificates."},
+	"dockerfile.runAsRoot":                         {Description: "Whether to skip the privilege drop to the appuser account."},
+	"dockerfile.user":                              {Description: "Removed. Set runAsRoot instead if you need to run as root.", Deprecated: true},
+	"dockerfile.withLinkerdAwait":                  {Description: "Whether to prepend linkerd-await to the entrypoint."},
+	"metadata":                                     {Description: "Information about the project that cannot be guessed consistently."},
+	"metadata.url":                                 {Description: "The repository's web URL, e.g. https://github.com/foo/bar. Defaults to the URL of the git remote named by metadata.remote, converted into this form."},
+	"metadata.remote":                              {Description: "Name of the git remote that metadata.url is derived from if it is not set.", Default: "origin"},
+	"testServices":                                 {Description: "Backing services for tests (like databases) that are detected from the dependencies in go.mod. Detected services get a service container in the CI workflow, connection variables in GO_TESTENV, and a helper script in testing/ for running them locally."},
+	"testServices.enabled":                         {Description: "Whether to provision the detected services.", Default: true},
+	"testServices.exclude":                         {Description: "Detected services that shall not be provisioned, e.g. \"redis\"."},
+	"toolVersions":                                 {Description: "Overrides for the versions of GitHub actions, images and tools that are used in the generated files."},
+}
+
+// ConfigurationSchema builds the JSON schema for type Configuration.
+func ConfigurationSchema() *JSONSchema {
+	s := schemaForType(reflect.TypeOf(Configuration{}), "")
+	// `extends` is resolved before decoding, so it does not appear in type Configuration
+	s.Properties["extends"] = schemaForType(reflect.TypeOf([]string(nil)), "extends")
+	// only the keys from DefaultToolVersions are accepted in `toolVersions`
+	toolVersions := s.Properties["toolVersions"]
+	toolVersions.Properties = make(map[string]*JSONSchema, len(DefaultToolVersions))
+	toolVersions.AdditionalProperties = false
+	for key, version := range DefaultToolVersions {
+		description, exists := toolVersionDocs[key]
+		if !exists {
+			description = fmt.Sprintf("Git ref of the GitHub action %s.", key)
+		}
+		toolVersions.Properties[key] = &JSONSchema{Type: "string", Description: description, Default: version}
+	}
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.ID = SchemaURL
+	s.Title = "Makefile.maker.yaml"
+	s.Description = "Configuration file for go-makefile-maker <https://github.com/sapcc/go-makefile-maker>"
+	return s
+}
+
+// schemaForType builds the schema for the config key at the given path,
+// including its documentation from fieldDocs.
+func schemaForType(t reflect.Type, path string) *JSONSchema {
+	s := typeSchema(t, path)
+	doc := fieldDocs[path]
+	if len(doc.Alternatives) > 0 {
+		s = &JSONSchema{AnyOf: append([]*JSONSchema{s}, doc.Alternatives...)}
+	}
+	s.Description = doc.Description
+	s.Default = doc.Default
+	s.Deprecated = doc.Deprecated
+	return s
+}
+
+// typeSchema builds the schema for the given type without any documentation.
+// Documentation is attached to the config key, not to list items or map values.
+func typeSchema(t reflect.Type, path string) *JSONSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var s JSONSchema
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		s.Type = "integer"
+	case reflect.Slice:
+		s.Type = "array"
+		s.Items = typeSchema(t.Elem(), path)
+	case reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = typeSchema(t.Elem(), path)
+	case reflect.Struct:
+		s.Type = "object"
+		s.Properties = make(map[string]*JSONSchema)
+		s.AdditionalProperties = false
+		for _, field := range reflect.VisibleFields(t) {
+			key := yamlKey(field)
+			if key == "" {
+				continue
+			}
+			s.Properties[key] = schemaForType(field.Type, joinPath(path, key))
+		}
+	default:
+		panic(fmt.Sprintf("cannot build JSON schema for %s at %q", t.String(), path))
+	}
+	return &s
+}
+
+// yamlKey returns the key under which the given field appears in YAML, or ""
+// if it does not appear in YAML at all.
+func yamlKey(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return ""
+	}
+	if key == "" {
+		return strings.ToLower(field.Name)
+	}
+	return key
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
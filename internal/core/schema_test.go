@@ -0,0 +1,53 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import "testing"
+
+func TestAllConfigKeysAreDocumented(t *testing.T) {
+	documentedPaths := make(map[string]bool)
+	var visit, visitChildren func(s *JSONSchema, path string)
+	visit = func(s *JSONSchema, path string) {
+		if path != "" {
+			documentedPaths[path] = true
+			if s.Description == "" {
+				t.Errorf("missing description for config key %s in fieldDocs", path)
+			}
+		}
+		visitChildren(s, path)
+	}
+	visitChildren = func(s *JSONSchema, path string) {
+		for key, prop := range s.Properties {
+			visit(prop, joinPath(path, key))
+		}
+		if s.Items != nil {
+			visitChildren(s.Items, path)
+		}
+		for _, alt := range s.AnyOf {
+			visitChildren(alt, path)
+		}
+	}
+	visit(ConfigurationSchema(), "")
+
+	for path := range fieldDocs {
+		if !documentedPaths[path] {
+			t.Errorf("fieldDocs contains documentation for nonexistent config key %s", path)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"slices"
+	"strings"
+)
+
+// TestService is a backing service that the tests of a repository need,
+// e.g. a database. Test services are detected from the dependencies in go.mod.
+type TestService struct {
+	// Name is used in `testServices.exclude` and as the key in `toolVersions`.
+	Name string
+	// Modules are the module paths that indicate a need for this service.
+	// Major version suffixes like "/v5" are matched automatically.
+	Modules []string
+	// IndirectModules are those of the Modules that also indicate a need for
+	// this service when they are only indirect dependencies. (For
+	// github.com/lib/pq, this is how the postgres script has always been
+	// detected, e.g. in applications that use it through go-bits/easypg.)
+	IndirectModules []string
+	// Image is the container image (without tag) that runs the service in CI.
+	// If empty, the service does not run in a container.
+	Image string
+	// ContainerEnv configures the container in CI.
+	ContainerEnv map[string]string
+	// ContainerPort is exposed as HostPort in CI. Locally, the helper script
+	// runs the service on HostPort as well.
+	ContainerPort int
+	HostPort      int
+	// HealthCmd is run inside the container to check whether the service is ready.
+	HealthCmd string
+	// TestEnv contains the connection variables that are put into GO_TESTENV.
+	TestEnv []string
+	// Script is the helper script in testing/ that runs a command with the
+	// service started locally.
+	Script string
+}
+
+// TestServiceCatalog contains all services that can be detected.
+var TestServiceCatalog = []TestService{
+	{
+		Name:            "postgres",
+		Modules:         []string{"github.com/lib/pq", "github.com/jackc/pgx"},
+		IndirectModules: []string{"github.com/lib/pq"},
+		Image:           "postgres",
+		ContainerEnv:    map[string]string{"POSTGRES_PASSWORD": "postgres"},
+		ContainerPort:   5432,
+		HostPort:        54321,
+		HealthCmd:       "pg_isready",
+		TestEnv:         []string{"PGHOST=localhost", "PGPORT=54321", "PGUSER=postgres", "PGPASSWORD=postgres"},
+		Script:          "with-postgres-db.sh",
+	},
+	{
+		Name:          "mariadb",
+		Modules:       []string{"github.com/go-sql-driver/mysql"},
+		Image:         "mariadb",
+		ContainerEnv:  map[string]string{"MARIADB_ALLOW_EMPTY_ROOT_PASSWORD": "1"},
+		ContainerPort: 3306,
+		HostPort:      33061,
+		HealthCmd:     "healthcheck.sh --connect --innodb_initialized",
+		TestEnv:       []string{"MYSQL_HOST=127.0.0.1", "MYSQL_PORT=33061", "MYSQL_USER=root"},
+		Script:        "with-mariadb-db.sh",
+	},
+	{
+		Name:          "redis",
+		Modules:       []string{"github.com/redis/go-redis", "github.com/go-redis/redis"},
+		Image:         "redis",
+		ContainerPort: 6379,
+		HostPort:      63791,
+		HealthCmd:     "redis-cli ping",
+		TestEnv:       []string{"REDIS_URL=redis://localhost:63791/0"},
+		Script:        "with-redis.sh",
+	},
+	{
+		Name:          "nats",
+		Modules:       []string{"github.com/nats-io/nats.go"},
+		Image:         "nats",
+		ContainerPort: 4222,
+		HostPort:      42221,
+		// the nats image does not contain a shell or any tools for a health check
+		TestEnv: []string{"NATS_URL=nats://localhost:42221"},
+		Script:  "with-nats.sh",
+	},
+	{
+		// the envtest binaries are downloaded instead of running in a container
+		Name:    "k8s-envtest",
+		Modules: []string{"sigs.k8s.io/controller-runtime"},
+		Script:  "with-envtest.sh",
+	},
+}
+
+// TestServiceByName returns the service with the given name from the TestServiceCatalog.
+func TestServiceByName(name string) (TestService, bool) {
+	idx := slices.IndexFunc(TestServiceCatalog, func(s TestService) bool { return s.Name == name })
+	if idx < 0 {
+		return TestService{}, false
+	}
+	return TestServiceCatalog[idx], true
+}
+
+// testServiceNames returns the names of all services in the TestServiceCatalog.
+func testServiceNames() []string {
+	result := make([]string, len(TestServiceCatalog))
+	for idx, s := range TestServiceCatalog {
+		result[idx] = s.Name
+	}
+	return result
+}
+
+// matchesModule returns whether the given module path indicates a need for this service.
+func (s TestService) matchesModule(modulePath string) bool {
+	return matchesAnyModule(s.Modules, modulePath)
+}
+
+// matchesIndirectModule is like matchesModule, but for indirect dependencies.
+func (s TestService) matchesIndirectModule(modulePath string) bool {
+	return matchesAnyModule(s.IndirectModules, modulePath)
+}
+
+func matchesAnyModule(modules []string, modulePath string) bool {
+	for _, m := range modules {
+		if modulePath == m || strings.HasPrefix(modulePath, m+"/") {
+			return true
+		}
+	}
+	return false
+}
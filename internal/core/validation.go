@@ -0,0 +1,328 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationIssue is an error or warning found while reading the configuration.
+type ValidationIssue struct {
+	// File is the name of the config file containing the offending key. It may
+	// be empty if the issue does not relate to a specific file.
+	File string
+	// Path is the YAML path of the offending key, e.g. "githubWorkflow.ci.runOn".
+	// It may be empty if the issue does not relate to a specific key.
+	Path string
+	// Line and Column locate the offending key in the configuration file. They
+	// are 0 if the location is not known. (Errors from the YAML decoder only
+	// have a line number.)
+	Line      int
+	Column    int
+	Message   string
+	IsWarning bool
+}
+
+// Format renders the issue in the conventional "file:line:col: message"
+// format that is understood by editors. The given file name is used if the
+// issue does not relate to a specific file.
+func (i ValidationIssue) Format(fileName string) string {
+	location := fileName
+	if i.File != "" {
+		location = i.File
+	}
+	if i.Line > 0 {
+		location += fmt.Sprintf(":%d", i.Line)
+		if i.Column > 0 {
+			location += fmt.Sprintf(":%d", i.Column)
+		}
+	}
+	msg := i.Message
+	if i.Path != "" {
+		msg = fmt.Sprintf("%s: %s", i.Path, msg)
+	}
+	return fmt.Sprintf("%s: %s", location, msg)
+}
+
+// ValidationIssues is a list of ValidationIssue.
+type ValidationIssues []ValidationIssue
+
+// HasErrors returns whether any of the issues is not a warning.
+func (issues ValidationIssues) HasErrors() bool {
+	for _, i := range issues {
+		if !i.IsWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfiguration reads, merges and validates the configuration file at the
+// given path, including all base files referenced via `extends`. Instead of
+// stopping at the first problem, all problems are collected and returned
+// together.
+func LoadConfiguration(path string) (Configuration, ValidationIssues) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return Configuration{}, ValidationIssues{{File: path, Message: err.Error()}}
+	}
+	return parseConfiguration(path, buf)
+}
+
+// ParseConfiguration is like LoadConfiguration, but takes the contents of
+// the configuration file. Base files are searched relative to the current
+// working directory.
+func ParseConfiguration(buf []byte) (Configuration, ValidationIssues) {
+	return parseConfiguration(ConfigFilename, buf)
+}
+
+func parseConfiguration(fileName string, buf []byte) (Configuration, ValidationIssues) {
+	var cfg Configuration
+	loader := newConfigLoader()
+	root, issues := loader.load(fileName, buf)
+	if root == nil {
+		if issues.HasErrors() {
+			return cfg, issues
+		}
+		// an empty file is not an error
+		root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	stripAppendTags(root)
+
+	// `binaries: auto` cannot be decoded into a list of binaries, so we take it
+	// out of the document before decoding
+	decodeRoot, autoDetectBinaries := withoutAutoBinaries(root)
+
+	// We do not use KnownFields(true) here because checkKeys() reports unknown
+	// keys with better error messages. Type errors were already reported by the
+	// loader for each file separately.
+	err := decodeRoot.Decode(&cfg)
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return cfg, append(issues, ValidationIssue{File: fileName, Message: err.Error()})
+	}
+	cfg.AutoDetectBinaries = autoDetectBinaries
+
+	v := validator{root: root, origins: loader.origins, repoDir: filepath.Dir(fileName)}
+	v.checkKeys(root, ConfigurationSchema(), "")
+	cfg.fillDefaultsFromGit(&v, newGitRepo(filepath.Dir(fileName)))
+	cfg.validate(&v)
+	return cfg, append(issues, v.issues...)
+}
+
+// withoutAutoBinaries returns a shallow copy of the given mapping node in
+// which the value of `binaries: auto` is replaced with an empty list, and
+// whether such a replacement took place.
+func withoutAutoBinaries(node *yaml.Node) (*yaml.Node, bool) {
+	if node.Kind != yaml.MappingNode {
+		return node, false
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+		if keyNode.Value == "binaries" && valueNode.Kind == yaml.ScalarNode && valueNode.Value == "auto" {
+			result := *node
+			result.Content = slices.Clone(node.Content)
+			result.Content[idx+1] = &yaml.Node{
+				Kind:   yaml.SequenceNode,
+				Tag:    "!!seq",
+				Line:   valueNode.Line,
+				Column: valueNode.Column,
+			}
+			return &result, true
+		}
+	}
+	return node, false
+}
+
+var yamlErrorLineRx = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func issueFromYAMLError(msg string) ValidationIssue {
+	match := yamlErrorLineRx.FindStringSubmatch(msg)
+	if match == nil {
+		return ValidationIssue{Message: msg}
+	}
+	line, _ := strconv.Atoi(match[1]) //nolint:errcheck // the regex ensures that this is a number
+	return ValidationIssue{Line: line, Message: match[2]}
+}
+
+// validator collects ValidationIssues while Configuration.validate() runs.
+type validator struct {
+	root    *yaml.Node
+	origins map[*yaml.Node]string // see type configLoader
+	repoDir string                // the directory containing the configuration file
+	issues  ValidationIssues
+}
+
+// Errorf records an error for the key at the given path.
+func (v *validator) Errorf(path, msg string, args ...any) {
+	v.add(path, false, msg, args...)
+}
+
+// Warnf records a warning for the key at the given path.
+func (v *validator) Warnf(path, msg string, args ...any) {
+	v.add(path, true, msg, args...)
+}
+
+func (v *validator) add(path string, isWarning bool, msg string, args ...any) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	issue := ValidationIssue{Path: path, Message: msg, IsWarning: isWarning}
+	if n := findNode(v.root, path); n != nil {
+		issue.File = v.origins[n]
+		issue.Line = n.Line
+		issue.Column = n.Column
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// checkKeys reports all keys below the given node that are not declared in
+// the given schema.
+func (v *validator) checkKeys(node *yaml.Node, schema *JSONSchema, path string) {
+	// if the key accepts several forms, continue with the one that fits the node
+	for _, alt := range schema.AnyOf {
+		if (alt.Type == "object" && node.Kind == yaml.MappingNode) || (alt.Type == "array" && node.Kind == yaml.SequenceNode) {
+			schema = alt
+			break
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+			keyPath := joinPath(path, keyNode.Value)
+			switch {
+			case schema.Properties != nil:
+				propSchema, exists := schema.Properties[keyNode.Value]
+				if exists {
+					v.checkKeys(valueNode, propSchema, keyPath)
+				} else {
+					msg := "unknown key"
+					if m, ok := migrationForKey(keyPath); ok {
+						msg = fmt.Sprintf("this key has been renamed to %q (run `go-makefile-maker migrate` to update the config)", m.NewKey)
+					} else if suggestion := closestKey(keyNode.Value, schema.Properties); suggestion != "" {
+						msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+					}
+					v.issues = append(v.issues, ValidationIssue{
+						File:    v.origins[keyNode],
+						Path:    keyPath,
+						Line:    keyNode.Line,
+						Column:  keyNode.Column,
+						Message: msg,
+					})
+				}
+			case schema.AdditionalProperties != nil:
+				if valueSchema, ok := schema.AdditionalProperties.(*JSONSchema); ok {
+					v.checkKeys(valueNode, valueSchema, keyPath)
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		if schema.Items != nil {
+			for idx, item := range node.Content {
+				v.checkKeys(item, schema.Items, joinPath(path, strconv.Itoa(idx)))
+			}
+		}
+	}
+}
+
+// closestKey returns the property name that is most similar to the given
+// unknown key, or "" if none is similar enough to be a likely typo.
+func closestKey(key string, properties map[string]*JSONSchema) string {
+	best, bestDistance := "", 3 // only suggest keys with at most 2 edits
+	for name := range properties {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		d := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// findNode locates the key at the given dot-separated path (numeric path
+// elements index into sequences). If the path does not exist in the document,
+// the node of the closest existing ancestor is returned instead, so that the
+// reported location is as close as possible to where the key would be.
+func findNode(root *yaml.Node, path string) *yaml.Node {
+	current := root
+	if current.Kind == yaml.DocumentNode && len(current.Content) > 0 {
+		current = current.Content[0]
+	}
+	if current.Kind == 0 || path == "" {
+		return nil
+	}
+
+	var located *yaml.Node
+	for _, key := range strings.Split(path, ".") {
+		var keyNode, valueNode *yaml.Node
+		switch current.Kind {
+		case yaml.MappingNode:
+			for idx := 0; idx+1 < len(current.Content); idx += 2 {
+				if current.Content[idx].Value == key {
+					keyNode, valueNode = current.Content[idx], current.Content[idx+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(key)
+			if err == nil && idx >= 0 && idx < len(current.Content) {
+				keyNode, valueNode = current.Content[idx], current.Content[idx]
+			}
+		}
+		if keyNode == nil {
+			break
+		}
+		located, current = keyNode, valueNode
+	}
+	return located
+}
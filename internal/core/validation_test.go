@@ -0,0 +1,249 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const invalidConfig = `
+dockerfile:
+  enabled: true
+  user: root
+golangciLint:
+  errcheckExcludes: [ io.Copy ]
+  skipDir: [ generated ]
+githubWorkflow:
+  global:
+    defaultBranch: main
+  ci:
+    runOn: [ macos-latest, ubuntu-latest ]
+    postgres:
+      enabled: true
+  release:
+    enabled: true
+`
+
+// fakeGitRepo replaces GitRepo in tests.
+type fakeGitRepo struct {
+	remotes       map[string]string
+	defaultBranch string
+}
+
+func (r fakeGitRepo) RemoteURL(remote string) (string, error) {
+	remoteURL, ok := r.remotes[remote]
+	if !ok {
+		return "", fmt.Errorf("no such remote %q", remote)
+	}
+	return CanonicalRepoURL(remoteURL)
+}
+
+func (r fakeGitRepo) DefaultBranch() (string, error) {
+	if r.defaultBranch == "" {
+		return "", errors.New("origin/HEAD is not set")
+	}
+	return r.defaultBranch, nil
+}
+
+func TestParseConfigurationCollectsAllIssues(t *testing.T) {
+	defer func(orig func(string) gitRepository) { newGitRepo = orig }(newGitRepo)
+	newGitRepo = func(string) gitRepository { return fakeGitRepo{} }
+
+	_, issues := ParseConfiguration([]byte(invalidConfig))
+	if !issues.HasErrors() {
+		t.Fatal("expected errors, but got none")
+	}
+
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		`Makefile.maker.yaml:7:3: golangciLint.skipDir: unknown key (did you mean "skipDirs"?)`,
+		`Makefile.maker.yaml: metadata.url: could not be derived from the git remote, please set it manually: no such remote "origin"`,
+		"Makefile.maker.yaml: metadata.url: must be set when dockerfile.enabled is true",
+		"Makefile.maker.yaml:2:1: dockerfile.entrypoint: must be set when dockerfile.enabled is true and no binaries are configured",
+		"Makefile.maker.yaml:4:3: dockerfile.user: this option has been removed; set `dockerfile.runAsRoot` if you need to run as root (run `go-makefile-maker migrate` to update the config)",
+		"Makefile.maker.yaml:6:3: golangciLint.errcheckExcludes: golangciLint.createConfig must be set to 'true' if golangciLint.errcheckExcludes is defined",
+		"Makefile.maker.yaml: metadata.url: must be set when any github workflow is configured otherwise it cannot be determined which github runner type should be used",
+		"Makefile.maker.yaml:11:3: githubWorkflow.ci.enabled: must be set to 'true' when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled",
+		"Makefile.maker.yaml:12:5: githubWorkflow.ci.runOn: must only define a single Ubuntu based runner when githubWorkflow.ci.postgres or githubWorkflow.ci.kubernetesEnvtest is enabled",
+		"Makefile.maker.yaml:16:5: githubWorkflow.release.enabled: the release workflow runs goreleaser, but goReleaser.createConfig is not set",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestToolVersionsAreValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("toolVersions:\n  action/checkout: v4.1.1\n  postgres: \"\"\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		`Makefile.maker.yaml:2:3: toolVersions.action/checkout: unknown key (did you mean "actions/checkout"?)`,
+		`Makefile.maker.yaml:3:3: toolVersions.postgres: must not be empty`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestTestServicesAreValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("testServices:\n  exclude:\n    - redis\n    - mysql\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		`Makefile.maker.yaml:4:7: testServices.exclude.1: unknown service "mysql" (known services are: postgres, mariadb, redis, nats, k8s-envtest)`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestDefaultsAreDerivedFromGit(t *testing.T) {
+	defer func(orig func(string) gitRepository) { newGitRepo = orig }(newGitRepo)
+	newGitRepo = func(string) gitRepository {
+		return fakeGitRepo{remotes: map[string]string{"upstream": "git@github.wdf.sap.corp:example/service.git"}, defaultBranch: "master"}
+	}
+
+	cfg, issues := ParseConfiguration([]byte("metadata:\n  remote: upstream\ndockerfile:\n  enabled: true\n  entrypoint: [ /usr/bin/service ]\ngithubWorkflow:\n  ci:\n    enabled: true\n"))
+	for _, issue := range issues {
+		t.Error(issue.Format("Makefile.maker.yaml"))
+	}
+	if expected := "https://github.wdf.sap.corp/example/service"; cfg.Metadata.URL != expected {
+		t.Errorf("expected metadata.url to be %q, but got %q", expected, cfg.Metadata.URL)
+	}
+	if expected := "master"; cfg.GitHubWorkflow.Global.DefaultBranch != expected {
+		t.Errorf("expected githubWorkflow.global.defaultBranch to be %q, but got %q", expected, cfg.GitHubWorkflow.Global.DefaultBranch)
+	}
+}
+
+func TestBinariesAreValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("binaries:\n  - name: foo\n    fromPackage: .\n    env: [ CGO_ENABLED=0, GOOS=linux, CGO_ENABLED ]\n  - name: bar\n    fromPackage: ./cmd/bar\n    goos: linux\ncrossCompile: [ linux/arm64, darwin ]\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		"Makefile.maker.yaml:4:27: binaries.0.env.1: use binaries.goos instead of setting GOOS in env",
+		"Makefile.maker.yaml:4:39: binaries.0.env.2: must have the form KEY=value",
+		`Makefile.maker.yaml:8:30: crossCompile.1: must have the form GOOS/GOARCH, e.g. "linux/arm64"`,
+		"Makefile.maker.yaml:5:5: binaries.1: is not built by `make build-cross` because it sets goos or goarch",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestGoTestIsValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("goTest:\n  parallelism: -1\n  timeout: 20\n  shuffle: yes\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		"Makefile.maker.yaml:2:3: goTest.parallelism: must not be negative",
+		`Makefile.maker.yaml:3:3: goTest.timeout: must be a duration like "10m": time: missing unit in duration "20"`,
+		`Makefile.maker.yaml:4:3: goTest.shuffle: must be "on", "off" or an integer seed`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestTestGroupsAreValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("testGroups:\n  - name: Unit\n  - name: e2e\n    env: [ FOO ]\n    services: [ mysql ]\n  - name: e2e\n    timeout: 1h\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		"Makefile.maker.yaml:2:5: testGroups.0.name: must only contain lowercase letters, digits and dashes",
+		"Makefile.maker.yaml:4:12: testGroups.1.env.0: must have the form KEY=value",
+		`Makefile.maker.yaml:5:17: testGroups.1.services.0: unknown service "mysql" (known services are: postgres, mariadb, redis, nats, k8s-envtest)`,
+		`Makefile.maker.yaml:6:5: testGroups.2.name: duplicate test group "e2e"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestTestGroupsRequireSingleModule(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module github.com/example/foo\n\ngo 1.21\n",
+		"api/go.mod": "module github.com/example/foo/api\n\ngo 1.21\n",
+	})
+
+	for nestedModules, expected := range map[bool][]string{
+		false: nil,
+		true:  {"Makefile.maker.yaml:3:1: testGroups: cannot be used in repositories with multiple modules (found modules in ., ./api)"},
+	} {
+		writeFiles(t, root, map[string]string{
+			"Makefile.maker.yaml": fmt.Sprintf("golang:\n  nestedModules: %t\ntestGroups:\n  - name: e2e\n", nestedModules),
+		})
+		_, issues := LoadConfiguration(filepath.Join(root, "Makefile.maker.yaml"))
+		var actual []string
+		for _, issue := range issues {
+			actual = append(actual, strings.TrimPrefix(issue.Format(ConfigFilename), root+string(filepath.Separator)))
+		}
+		if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected issues for nestedModules = %t:\n%s\nbut got:\n%s", nestedModules, strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
+
+func TestBenchmarksAreValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("metadata:\n  url: https://github.com/example/foo\nbenchmarks:\n  count: -1\n  pattern: \"it's\"\ngithubWorkflow:\n  global:\n    defaultBranch: main\n  ci:\n    enabled: true\n    benchmarks: true\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		"Makefile.maker.yaml:4:3: benchmarks.count: must not be negative",
+		"Makefile.maker.yaml:5:3: benchmarks.pattern: must not contain single quotes",
+		"Makefile.maker.yaml:3:1: benchmarks: has no effect unless benchmarks.enabled is set",
+		"Makefile.maker.yaml:11:5: githubWorkflow.ci.benchmarks: must not be set unless benchmarks.enabled is set",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestFuzzTimeIsValidated(t *testing.T) {
+	_, issues := ParseConfiguration([]byte("metadata:\n  url: https://github.com/example/foo\nfuzzing:\n  fuzzTime: 1000\ngithubWorkflow:\n  global:\n    defaultBranch: main\n  fuzzing:\n    enabled: true\n    fuzzTime: 500x\n"))
+	var actual []string
+	for _, issue := range issues {
+		actual = append(actual, issue.Format("Makefile.maker.yaml"))
+	}
+	expected := []string{
+		`Makefile.maker.yaml:4:3: fuzzing.fuzzTime: must be a duration like "10m" or a number of iterations like "1000x"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
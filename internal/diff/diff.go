@@ -0,0 +1,180 @@
+// Copyright 2023 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// Unified returns a unified diff between oldText and newText, or an empty
+// string if both are identical. The names are used in the "---" and "+++"
+// header lines; pass "/dev/null" for a file that does not exist.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+	edits := computeEdits(oldLines, newLines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range groupHunks(edits) {
+		h.render(&out, oldLines, newLines)
+	}
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is a single line of the diff. For editEqual and editDelete, oldIdx
+// points into the old lines; for editEqual and editInsert, newIdx points into
+// the new lines.
+type edit struct {
+	kind   editKind
+	oldIdx int
+	newIdx int
+}
+
+// computeEdits finds a minimal line-based edit script by solving the longest
+// common subsequence problem. Generated files are small enough that the
+// quadratic memory usage is not a concern.
+func computeEdits(a, b []string) []edit {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{editEqual, i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, edit{editInsert, i, j})
+			j++
+		default:
+			edits = append(edits, edit{editDelete, i, j})
+			i++
+		}
+	}
+	return edits
+}
+
+// hunk is a contiguous range of edits that is rendered with one "@@" header.
+type hunk struct {
+	edits []edit
+}
+
+func groupHunks(edits []edit) []hunk {
+	var result []hunk
+	start := -1 // index of first edit in the current hunk
+	lastChange := -1
+	for idx, e := range edits {
+		if e.kind == editEqual {
+			continue
+		}
+		if start >= 0 && idx-lastChange-1 > 2*contextLines {
+			// close the current hunk, the gap to this change is too large
+			result = append(result, hunk{edits[start:min(lastChange+contextLines+1, len(edits))]})
+			start = -1
+		}
+		if start < 0 {
+			start = max(idx-contextLines, 0)
+		}
+		lastChange = idx
+	}
+	if start >= 0 {
+		result = append(result, hunk{edits[start:min(lastChange+contextLines+1, len(edits))]})
+	}
+	return result
+}
+
+func (h hunk) render(out *strings.Builder, oldLines, newLines []string) {
+	first := h.edits[0]
+	oldCount, newCount := 0, 0
+	for _, e := range h.edits {
+		if e.kind != editInsert {
+			oldCount++
+		}
+		if e.kind != editDelete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(first.oldIdx, oldCount), hunkRange(first.newIdx, newCount))
+
+	for _, e := range h.edits {
+		var prefix, line string
+		switch e.kind {
+		case editEqual:
+			prefix, line = " ", oldLines[e.oldIdx]
+		case editDelete:
+			prefix, line = "-", oldLines[e.oldIdx]
+		case editInsert:
+			prefix, line = "+", newLines[e.newIdx]
+		}
+		out.WriteString(prefix + line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a line range in the way expected by patch(1): line
+// numbers are 1-based, and an empty range refers to the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleText = `one
+two
+three
+four
+five
+six
+seven
+eight
+nine
+ten
+eleven
+twelve
+`
+
+func TestUnified(t *testing.T) {
+	if d := Unified("a/x", "b/x", sampleText, sampleText); d != "" {
+		t.Errorf("expected no diff for identical inputs, got:\n%s", d)
+	}
+
+	modified := strings.Replace(sampleText, "two\n", "zwei\n", 1)
+	modified = strings.Replace(modified, "eleven\n", "", 1)
+	expected := `--- a/x
++++ b/x
+@@ -1,5 +1,5 @@
+ one
+-two
++zwei
+ three
+ four
+ five
+@@ -8,5 +8,4 @@
+ eight
+ nine
+ ten
+-eleven
+ twelve
+`
+	checkDiff(t, Unified("a/x", "b/x", sampleText, modified), expected)
+
+	expected = `--- /dev/null
++++ b/x
+@@ -0,0 +1,2 @@
++one
++two
+`
+	checkDiff(t, Unified("/dev/null", "b/x", "", "one\ntwo\n"), expected)
+
+	expected = `--- a/x
++++ b/x
+@@ -1 +1 @@
+-one
++one
+\ No newline at end of file
+`
+	checkDiff(t, Unified("a/x", "b/x", "one\n", "one"), expected)
+}
+
+func checkDiff(t *testing.T, actual, expected string) {
+	t.Helper()
+	if actual != expected {
+		t.Error("unexpected diff")
+		for _, line := range strings.SplitAfter(actual, "\n") {
+			t.Logf("output line: %q", line)
+		}
+	}
+}
@@ -16,37 +16,59 @@ package dockerfile
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	_ "embed"
 
-	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 
 	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
-func RenderConfig(cfg core.Configuration) {
-	if cfg.Dockerfile.User != "" {
-		if cfg.Dockerfile.User == "root" {
-			logg.Fatal("the `dockerfile.user` config option has been removed; set `dockerfile.runAsRoot` if you need to run as root")
-		} else {
-			logg.Fatal("the `dockerfile.user` config option has been removed; commands now run as user `appuser` (ID 4200) in group `appgroup` (ID 4200)")
-		}
-	}
-
+func RenderConfig(sink core.OutputSink, cfg core.Configuration, sr core.ScanResult) {
 	var goBuildflags, packages, userCommand, entrypoint, workingDir, addUserGroup, extraCommands string
 
+	// if go.mod asks for a specific toolchain, build with exactly that one
+	golangImage := cfg.ToolVersions.Get("golang")
+	switch {
+	case cfg.ToolVersions["golang"] != "":
+		core.Explain(sink, "Dockerfile", "the builder uses golang:%s because toolVersions.golang is set", golangImage)
+	case sr.Toolchain != "":
+		golangImage = sr.GoToolchainVersion() + "-alpine"
+		core.Explain(sink, "Dockerfile", "the builder uses golang:%s because go.mod asks for toolchain %s", golangImage, sr.Toolchain)
+	}
+
 	if cfg.Golang.EnableVendoring {
 		goBuildflags = ` GO_BUILDFLAGS='-mod vendor'`
 	}
 
+	// a C toolchain is only left out if cgo is known to be disabled
+	builderPackages := "gcc git make musl-dev"
+	cgoEnabled := cfg.Golang.CGOEnabled(sr)
+	makeArgs := ""
+	if cgoEnabled != "" {
+		makeArgs = " CGO_ENABLED=" + cgoEnabled
+		core.Explain(sink, "Dockerfile", "the builder uses CGO_ENABLED=%s because %s", cgoEnabled, cfg.Golang.ExplainCGO(sr))
+	} else {
+		core.Explain(sink, "Dockerfile", "the builder has a C toolchain and leaves CGO_ENABLED to the go command because %s", cfg.Golang.ExplainCGO(sr))
+	}
+	if cgoEnabled == "0" {
+		builderPackages = "git make"
+	}
+	for _, bin := range cfg.Binaries {
+		if cgoEnabled == "0" && bin.UsesCGO(cfg.Golang, sr) {
+			builderPackages = "gcc git make musl-dev"
+			core.Explain(sink, "Dockerfile", "the builder has a C toolchain because binaries sets CGO_ENABLED=1 in the env for %s", bin.Name)
+			break
+		}
+	}
+
 	for _, v := range append([]string{"ca-certificates"}, cfg.Dockerfile.ExtraPackages...) {
 		packages += fmt.Sprintf(" %s", v)
 	}
 
 	if cfg.Dockerfile.RunAsRoot {
+		core.Explain(sink, "Dockerfile", "the image runs as root because dockerfile.runAsRoot is set")
 		userCommand = ""
 		workingDir = "/"
 		addUserGroup = ""
@@ -70,10 +92,11 @@ func RenderConfig(cfg core.Configuration) {
 	}
 
 	if cfg.Dockerfile.WithLinkerdAwait {
+		core.Explain(sink, "Dockerfile", "the entrypoint is wrapped in linkerd-await because dockerfile.withLinkerdAwait is set")
 		extraCommands = fmt.Sprintf(`
 RUN wget -qO /usr/bin/linkerd-await https://github.com/linkerd/linkerd-await/releases/download/release%%2Fv%[1]s/linkerd-await-v%[1]s-amd64 \
   && chmod 755 /usr/bin/linkerd-await
-`, core.DefaultLinkerdAwaitVersion)
+`, cfg.ToolVersions.Get("linkerd-await"))
 
 		// add linkrd-await after the fallback for entrypoint has been set
 		entrypoint = `"/usr/bin/linkerd-await", "--shutdown", "--", ` + entrypoint
@@ -87,11 +110,11 @@ RUN wget -qO /usr/bin/linkerd-await https://github.com/linkerd/linkerd-await/rel
 	dockerfile := fmt.Sprintf(
 		`FROM golang:%[1]s%[2]s as builder
 
-RUN apk add --no-cache --no-progress gcc git make musl-dev
+RUN apk add --no-cache --no-progress %[12]s
 
 COPY . /src
 ARG BININFO_BUILD_DATE BININFO_COMMIT_HASH BININFO_VERSION # provided to 'make install'
-RUN make -C /src install PREFIX=/pkg GOTOOLCHAIN=local%[3]s
+RUN make -C /src install PREFIX=/pkg GOTOOLCHAIN=local%[13]s%[3]s
 
 ################################################################################
 
@@ -114,9 +137,10 @@ LABEL source_repository="%[7]s" \
 
 %[8]s%[9]sWORKDIR %[10]s
 ENTRYPOINT [ %[11]s ]
-`, core.DefaultGolangImagePrefix, core.DefaultAlpineImage, goBuildflags, addUserGroup, packages, extraCommands, cfg.Metadata.URL, extraDirectives, userCommand, workingDir, entrypoint)
+`, golangImage, cfg.ToolVersions.Get("alpine"), goBuildflags, addUserGroup, packages, extraCommands, cfg.Metadata.URL, extraDirectives, userCommand, workingDir, entrypoint, builderPackages, makeArgs)
 
-	must.Succeed(os.WriteFile("Dockerfile", []byte(dockerfile), 0666))
+	dockerfile = core.AutogeneratedHeader + "\n\n" + dockerfile
+	must.Succeed(sink.WriteFile("Dockerfile", []byte(dockerfile), 0666))
 
 	dockerignoreLines := append([]string{
 		`.dockerignore`,
@@ -138,7 +162,7 @@ ENTRYPOINT [ %[11]s ]
 		`shell.nix`,
 		`/testing/`,
 	}, cfg.Dockerfile.ExtraIgnores...)
-	dockerignore := strings.Join(dockerignoreLines, "\n") + "\n"
+	dockerignore := core.AutogeneratedHeader + "\n\n" + strings.Join(dockerignoreLines, "\n") + "\n"
 
-	must.Succeed(os.WriteFile(".dockerignore", []byte(dockerignore), 0666))
+	must.Succeed(sink.WriteFile(".dockerignore", []byte(dockerignore), 0666))
 }
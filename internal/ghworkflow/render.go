@@ -15,12 +15,13 @@
 package ghworkflow
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 
 	"github.com/sapcc/go-makefile-maker/internal/core"
@@ -29,33 +30,85 @@ import (
 const workflowDir = ".github/workflows"
 
 // Render renders GitHub workflows.
-func Render(cfg *core.Configuration) {
+func Render(sink core.OutputSink, cfg *core.Configuration, sr core.ScanResult) {
 	ghwCfg := cfg.GitHubWorkflow
+	ghwCfg.ToolVersions = cfg.ToolVersions
+	ghwCfg.ActionLock = sr.ActionLock
+	ghwCfg.TestServices = cfg.TestServices.Services(sr)
+	ghwCfg.TestGroups = cfg.TestGroups
+	if ghwCfg.IsSelfHostedRunner {
+		core.Explain(sink, workflowDir, "all jobs run on self-hosted runners because metadata.url %q is not on https://github.com", cfg.Metadata.URL)
+	}
+	if ghwCfg.PinActions.Enabled {
+		core.Explain(sink, workflowDir, "all actions are pinned to the commits in %s because githubWorkflow.pinActions.enabled is set", core.ActionLockFilename)
+	}
 
-	must.Succeed(os.MkdirAll(workflowDir, 0o755))
+	// remove files that were renamed before core.ManifestSink took care of stale files
+	must.Succeed(sink.RemoveFile(path.Join(workflowDir, "dependency-review.yaml")))
+	must.Succeed(sink.RemoveFile(path.Join(workflowDir, "license.yaml")))
+	must.Succeed(sink.RemoveFile(path.Join(workflowDir, "spell.yaml")))
 
-	// remove renamed files
-	must.Succeed(os.RemoveAll(filepath.Join(workflowDir, "dependency-review.yaml")))
-	must.Succeed(os.RemoveAll(filepath.Join(workflowDir, "license.yaml")))
-	must.Succeed(os.RemoveAll(filepath.Join(workflowDir, "spell.yaml")))
+	checksWorkflow(sink, ghwCfg, sr, cfg.SpellCheck.IgnoreWords)
 
-	checksWorkflow(ghwCfg, cfg.SpellCheck.IgnoreWords)
-
-	ciWorkflow(ghwCfg, len(cfg.Binaries) > 0)
-	ghcrWorkflow(ghwCfg)
-	releaseWorkflow(ghwCfg)
-	codeQLWorkflow(ghwCfg)
+	ciWorkflow(sink, ghwCfg, sr, len(cfg.Binaries) > 0)
+	ghcrWorkflow(sink, ghwCfg)
+	releaseWorkflow(sink, ghwCfg)
+	codeQLWorkflow(sink, ghwCfg)
+	fuzzWorkflow(sink, ghwCfg, sr)
 }
 
-func writeWorkflowToFile(w *workflow) {
-	f := must.Return(os.Create(w.getPath()))
-	defer f.Close()
+func writeWorkflowToFile(sink core.OutputSink, w *workflow, cfg *core.GithubWorkflowConfiguration) {
+	// apply version overrides to all actions in one place
+	for _, j := range w.Jobs {
+		for idx, step := range j.Steps {
+			if step.Uses != "" {
+				j.Steps[idx].Uses = cfg.ToolVersions.Action(step.Uses)
+			}
+		}
+	}
+
+	var node yaml.Node
+	must.Succeed(node.Encode(w))
+	if cfg.PinActions.Enabled {
+		pinActions(&node, w.getPath(), cfg)
+	}
 
+	f := &bytes.Buffer{}
 	encoder := yaml.NewEncoder(f)
-	defer encoder.Close()
 	encoder.SetIndent(2)
 
 	fmt.Fprintln(f, core.AutogeneratedHeader)
 	fmt.Fprintln(f, "")
-	must.Succeed(encoder.Encode(w))
+	must.Succeed(encoder.Encode(&node))
+	must.Succeed(encoder.Close())
+
+	must.Succeed(sink.WriteFile(w.getPath(), f.Bytes(), 0666))
+}
+
+// pinActions replaces the refs of all actions below the given node with the
+// commits from the lock file, and puts the version into a comment, e.g.
+// `uses: actions/checkout@<sha> # v4.1.1`. This is done on the YAML node
+// level because the comments cannot be expressed in type jobStep.
+func pinActions(node *yaml.Node, filePath string, cfg *core.GithubWorkflowConfiguration) {
+	if node.Kind == yaml.MappingNode {
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+			if keyNode.Value != "uses" || valueNode.Kind != yaml.ScalarNode {
+				continue
+			}
+			pinned, version, ok := cfg.ActionLock.Pin(valueNode.Value)
+			switch {
+			case ok:
+				valueNode.Value = pinned
+				valueNode.LineComment = version
+			case cfg.PinActions.Strict:
+				logg.Fatal("%s: %s is not pinned in %s, run `go-makefile-maker pin-actions` to update it", filePath, valueNode.Value, core.ActionLockFilename)
+			default:
+				logg.Other("WARNING", "%s: %s is not pinned in %s, run `go-makefile-maker pin-actions` to update it", filePath, valueNode.Value, core.ActionLockFilename)
+			}
+		}
+	}
+	for _, child := range node.Content {
+		pinActions(child, filePath, cfg)
+	}
 }
@@ -75,6 +75,17 @@ func baseJobWithGo(name string, isSelfHostedRunner bool, goVersion string) job {
 	return j
 }
 
+// cacheAllModules makes the "Set up Go" step of the given job consider the
+// go.sum files of all modules for its dependency cache, instead of only the
+// one in the repository root.
+func (j *job) cacheAllModules() {
+	for _, step := range j.Steps {
+		if step.Uses == core.SetupGoAction {
+			step.With["cache-dependency-path"] = "**/go.sum"
+		}
+	}
+}
+
 // makeMultilineYAMLString adds \n to the strings and joins them.
 // yaml.Marshal() takes care of the rest.
 func makeMultilineYAMLString(in []string) string {
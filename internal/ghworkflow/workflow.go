@@ -15,11 +15,12 @@
 package ghworkflow
 
 import (
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
 func newWorkflow(name, defaultBranch string, ignorePaths []string) *workflow {
@@ -45,12 +46,22 @@ type workflow struct {
 
 func (w workflow) getPath() string {
 	fileName := strings.ToLower(strings.ReplaceAll(w.Name, " ", "-"))
-	return filepath.Join(workflowDir, fileName+".yaml")
+	return path.Join(workflowDir, fileName+".yaml")
+}
+
+// explain reports why this workflow was generated the way it was (see core.Explain).
+func (w workflow) explain(sink core.OutputSink, reason string, args ...any) {
+	core.Explain(sink, w.getPath(), reason, args...)
+}
+
+// explainJob reports why the given job was generated the way it was (see core.Explain).
+func (w workflow) explainJob(sink core.OutputSink, jobID, reason string, args ...any) {
+	core.Explain(sink, w.getPath()+":"+jobID, reason, args...)
 }
 
-func (w workflow) deleteIf(condition bool) bool {
+func (w workflow) deleteIf(sink core.OutputSink, condition bool) bool {
 	if !condition {
-		must.Succeed(os.RemoveAll(w.getPath()))
+		must.Succeed(sink.RemoveFile(w.getPath()))
 		return true
 	}
 
@@ -21,11 +21,19 @@ import (
 )
 
 // basically a collection of other linters and checks which run fast to reduce the amount of created githbu action workflows
-func checksWorkflow(cfg *core.GithubWorkflowConfiguration, ignoreWords []string) {
+func checksWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration, sr core.ScanResult, ignoreWords []string) {
 	w := newWorkflow("Checks", cfg.Global.DefaultBranch, nil)
 	j := baseJobWithGo("Checks", cfg.IsSelfHostedRunner, cfg.Global.GoVersion)
+	if sr.IsMultiModule() {
+		j.cacheAllModules()
+	}
 
+	w.explain(sink, "always generated when githubWorkflow is set")
+	if cfg.SecurityChecks.Enabled && cfg.IsSelfHostedRunner {
+		w.explainJob(sink, "checks", "does not run dependency review and govulncheck despite githubWorkflow.securityChecks.enabled because it runs on self-hosted runners")
+	}
 	if cfg.SecurityChecks.Enabled && !cfg.IsSelfHostedRunner {
+		w.explainJob(sink, "checks", "runs dependency review and govulncheck because githubWorkflow.securityChecks.enabled is set")
 		j.addStep(jobStep{
 			Name: "Dependency Review",
 			Uses: core.DependencyReviewAction,
@@ -37,13 +45,24 @@ func checksWorkflow(cfg *core.GithubWorkflowConfiguration, ignoreWords []string)
 			},
 		})
 
-		j.addStep(jobStep{
-			Name: "Run govulncheck",
-			Uses: core.GovulncheckAction,
-		})
+		for _, m := range sr.Modules {
+			step := jobStep{
+				Name: "Run govulncheck",
+				Uses: core.GovulncheckAction,
+			}
+			if m.Dir != "." {
+				step.Name += " in " + m.Dir
+				step.With = map[string]any{"work-dir": m.Dir}
+			}
+			j.addStep(step)
+		}
 	}
 
+	if cfg.SpellCheck.Enabled && cfg.IsSelfHostedRunner {
+		w.explainJob(sink, "checks", "does not run misspell despite githubWorkflow.spellCheck.enabled because it runs on self-hosted runners")
+	}
 	if cfg.SpellCheck.Enabled && !cfg.IsSelfHostedRunner {
+		w.explainJob(sink, "checks", "runs misspell because githubWorkflow.spellCheck.enabled is set")
 		with := map[string]any{
 			"exclude":       "./vendor/*",
 			"reporter":      "github-check",
@@ -64,6 +83,7 @@ func checksWorkflow(cfg *core.GithubWorkflowConfiguration, ignoreWords []string)
 	}
 
 	if cfg.License.Enabled {
+		w.explainJob(sink, "checks", "checks license headers because githubWorkflow.license.enabled is set")
 		// Default behavior is to check all Go files excluding the vendor directory.
 		patterns := []string{"**/*.go"}
 		if len(cfg.License.Patterns) > 0 {
@@ -95,5 +115,5 @@ func checksWorkflow(cfg *core.GithubWorkflowConfiguration, ignoreWords []string)
 
 	w.Jobs = map[string]job{"checks": j}
 
-	writeWorkflowToFile(w)
+	writeWorkflowToFile(sink, w, cfg)
 }
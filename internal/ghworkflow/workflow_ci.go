@@ -18,20 +18,27 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/sapcc/go-bits/logg"
+
 	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
-func ciWorkflow(cfg *core.GithubWorkflowConfiguration, hasBinaries bool) {
+func ciWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration, sr core.ScanResult, hasBinaries bool) {
 	w := newWorkflow("CI", cfg.Global.DefaultBranch, cfg.CI.IgnorePaths)
 
-	if w.deleteIf(cfg.CI.Enabled) {
+	if w.deleteIf(sink, cfg.CI.Enabled) {
+		w.explain(sink, "not generated because githubWorkflow.ci.enabled is not set")
 		return
 	}
+	w.explain(sink, "githubWorkflow.ci.enabled is set")
 
 	w.Jobs = make(map[string]job)
 	goVersion := cfg.Global.GoVersion
 
 	buildAndLintJob := baseJobWithGo("Build & Lint", cfg.IsSelfHostedRunner, goVersion)
+	if sr.IsMultiModule() {
+		buildAndLintJob.cacheAllModules()
+	}
 	if hasBinaries {
 		buildAndLintJob.addStep(jobStep{
 			Name: "Build all binaries",
@@ -39,38 +46,208 @@ func ciWorkflow(cfg *core.GithubWorkflowConfiguration, hasBinaries bool) {
 		})
 	}
 
-	buildAndLintJob.addStep(jobStep{
-		Name: "Run golangci-lint",
-		Uses: core.GolangciLintAction,
-		With: map[string]any{
-			"version": "latest",
-		},
-	})
+	for _, m := range sr.Modules {
+		step := jobStep{
+			Name: "Run golangci-lint",
+			Uses: core.GolangciLintAction,
+			With: map[string]any{
+				"version": "latest",
+			},
+		}
+		if m.Dir != "." {
+			step.Name += " in " + m.Dir
+			step.With["working-directory"] = m.Dir
+		}
+		buildAndLintJob.addStep(step)
+	}
 
 	w.Jobs["buildAndLint"] = buildAndLintJob
+	w.explainJob(sink, "buildAndLint", "always generated")
+	if hasBinaries {
+		w.explainJob(sink, "buildAndLint", "runs `make build-all` because there are binaries")
+	}
+	if sr.IsMultiModule() {
+		w.explainJob(sink, "buildAndLint", "runs golangci-lint once per module because the repository contains multiple modules")
+	}
 
-	testJob := buildOrTestBaseJob("Test", cfg.IsSelfHostedRunner, cfg.CI.RunnerType, goVersion)
-	testJob.Needs = []string{"buildAndLint"}
+	// service containers are only supported on Linux runners (this is
+	// validated for the explicitly enabled ones)
+	canRunServices := len(cfg.CI.RunnerType) == 0 || (len(cfg.CI.RunnerType) == 1 && strings.HasPrefix(cfg.CI.RunnerType[0], "ubuntu"))
+	services := cfg.TestServices
+	if !canRunServices {
+		for _, s := range services {
+			logg.Other("WARNING", "not adding the %s test service to the CI workflow because githubWorkflow.ci.runOn contains runners other than a single Ubuntu runner", s.Name)
+			w.explain(sink, "does not start the %s test service because githubWorkflow.ci.runOn contains runners other than a single Ubuntu runner", s.Name)
+		}
+		services = nil
+	}
+
+	// with multiple test jobs, their coverage reports are merged by Coveralls
+	multipleOS := len(cfg.CI.RunnerType) > 1
+	parallelCoveralls := multipleOS || len(cfg.TestGroups) > 1
+	var testJobIDs []string
+	if len(cfg.TestGroups) == 0 {
+		coverallsFlag := ""
+		if multipleOS {
+			coverallsFlag = "Unit-${{ matrix.os }}"
+		}
+		w.Jobs["test"] = testJob(sink, w, cfg, sr, testJobParams{
+			ID:            "test",
+			Name:          "Test",
+			Services:      services,
+			MakeTarget:    "build/cover.out",
+			CoverFile:     "build/cover.out",
+			CoverallsFlag: coverallsFlag,
+		})
+		testJobIDs = append(testJobIDs, "test")
+	} else {
+		for _, tg := range cfg.TestGroups {
+			coverallsFlag := ""
+			switch {
+			case multipleOS:
+				coverallsFlag = tg.Name + "-${{ matrix.os }}"
+			case parallelCoveralls:
+				coverallsFlag = tg.Name
+			}
+			jobID := "test-" + tg.Name
+			w.explainJob(sink, jobID, "testGroups contains an entry for %s", tg.Name)
+			if tg.Services != nil {
+				w.explainJob(sink, jobID, "only provides the test services listed in testGroups.services")
+			}
+			w.Jobs[jobID] = testJob(sink, w, cfg, sr, testJobParams{
+				ID:            jobID,
+				Name:          fmt.Sprintf("Test (%s)", tg.Name),
+				Services:      tg.FilterServices(services),
+				MakeTarget:    jobID,
+				CoverFile:     fmt.Sprintf("build/cover-%s.out", tg.Name),
+				CoverallsFlag: coverallsFlag,
+			})
+			testJobIDs = append(testJobIDs, jobID)
+		}
+	}
+
+	if cfg.CI.Coveralls && !cfg.IsSelfHostedRunner && parallelCoveralls {
+		// 04. Tell Coveralls to merge coverage results.
+		finishJob := baseJobWithGo("Finish", cfg.IsSelfHostedRunner, goVersion)
+		finishJob.Needs = testJobIDs
+		finishJob.addStep(jobStep{
+			Name: "Coveralls post build webhook",
+			Run:  makeMultilineYAMLString([]string{installGoveralls, "goveralls -parallel-finish"}),
+			Env:  coverallsEnv,
+		})
+		w.Jobs["finish"] = finishJob
+		if multipleOS {
+			w.explainJob(sink, "finish", "githubWorkflow.ci.coveralls is set and githubWorkflow.ci.runOn contains multiple runners, so Coveralls needs to merge their results")
+		} else {
+			w.explainJob(sink, "finish", "githubWorkflow.ci.coveralls is set and there are multiple test groups, so Coveralls needs to merge their results")
+		}
+	}
+
+	if cfg.CI.Benchmarks {
+		benchJob := baseJobWithGo("Benchmarks", cfg.IsSelfHostedRunner, goVersion)
+		benchJob.Needs = []string{"buildAndLint"}
+		if sr.IsMultiModule() {
+			benchJob.cacheAllModules()
+		}
+		// the benchmarks may need the same backing services as the tests
+		addTestServices(sink, w, cfg, &benchJob, "benchmarks", services)
+		benchJob.addStep(jobStep{
+			Name: "Run benchmarks and compare against baseline",
+			Run:  "make bench-compare",
+		})
+		benchJob.addStep(jobStep{
+			Name: "Add comparison to job summary",
+			Run: makeMultilineYAMLString([]string{
+				`echo '### Benchmark comparison' >> "$GITHUB_STEP_SUMMARY"`,
+				"echo '```' >> \"$GITHUB_STEP_SUMMARY\"",
+				`cat build/bench/compare.txt >> "$GITHUB_STEP_SUMMARY"`,
+				"echo '```' >> \"$GITHUB_STEP_SUMMARY\"",
+			}),
+		})
+		w.Jobs["benchmarks"] = benchJob
+		w.explainJob(sink, "benchmarks", "githubWorkflow.ci.benchmarks is set")
+	}
+
+	writeWorkflowToFile(sink, w, cfg)
+}
+
+const installGoveralls = "go install github.com/mattn/goveralls@latest"
+
+var coverallsEnv = map[string]string{
+	"GIT_BRANCH":      "${{ github.head_ref }}",
+	"COVERALLS_TOKEN": "${{ secrets.GITHUB_TOKEN }}",
+}
+
+// testJobParams contains the parameters for testJob().
+type testJobParams struct {
+	ID         string
+	Name       string
+	Services   []core.TestService
+	MakeTarget string
+	CoverFile  string
+	// If not empty, the coverage report is uploaded to Coveralls in parallel
+	// mode with this flag name, and the "finish" job has to merge the results.
+	CoverallsFlag string
+}
+
+// testJob builds a job that runs the tests (via the given make target) with
+// the given test services.
+func testJob(sink core.OutputSink, w *workflow, cfg *core.GithubWorkflowConfiguration, sr core.ScanResult, p testJobParams) job {
+	j := buildOrTestBaseJob(p.Name, cfg.IsSelfHostedRunner, cfg.CI.RunnerType, cfg.Global.GoVersion)
+	j.Needs = []string{"buildAndLint"}
+	w.explainJob(sink, p.ID, "always generated")
+	if sr.IsMultiModule() {
+		j.cacheAllModules()
+	}
+	addTestServices(sink, w, cfg, &j, p.ID, p.Services)
+	j.addStep(jobStep{
+		Name: "Run tests and generate coverage report",
+		Run:  "make " + p.MakeTarget,
+	})
+	if cfg.CI.Coveralls && cfg.IsSelfHostedRunner {
+		w.explainJob(sink, p.ID, "does not upload to Coveralls despite githubWorkflow.ci.coveralls because it runs on self-hosted runners")
+	}
+	if cfg.CI.Coveralls && !cfg.IsSelfHostedRunner {
+		w.explainJob(sink, p.ID, "uploads the coverage report to Coveralls because githubWorkflow.ci.coveralls is set")
+		cmd := "goveralls -service=github -coverprofile=" + p.CoverFile
+		if p.CoverallsFlag != "" {
+			cmd += fmt.Sprintf(` -parallel -flagname="%s"`, p.CoverallsFlag)
+		}
+		j.addStep(jobStep{
+			Name: "Upload coverage report to Coveralls",
+			Run:  makeMultilineYAMLString([]string{installGoveralls, cmd}),
+			Env:  coverallsEnv,
+		})
+	}
+	return j
+}
+
+// addTestServices adds the given test services to the given job, as well as
+// the envtest binaries if needed.
+func addTestServices(sink core.OutputSink, w *workflow, cfg *core.GithubWorkflowConfiguration, j *job, jobID string, services []core.TestService) {
+	useEnvtest := cfg.CI.KubernetesEnvtest.Enabled
+	for _, s := range services {
+		w.explainJob(sink, jobID, "provides the %s test service because go.mod requires %s", s.Name, strings.Join(s.Modules, " or "))
+		if s.Image == "" {
+			useEnvtest = useEnvtest || s.Name == "k8s-envtest"
+			continue
+		}
+		j.addService(s, cfg.ToolVersions.Get(s.Name))
+	}
 	if cfg.CI.Postgres.Enabled {
-		version := core.DefaultPostgresVersion
+		version := cfg.ToolVersions.Get("postgres")
 		if cfg.CI.Postgres.Version != "" {
 			version = cfg.CI.Postgres.Version
 		}
-		testJob.Services = map[string]jobService{"postgres": {
-			Image: "postgres:" + version,
-			Env:   map[string]string{"POSTGRES_PASSWORD": "postgres"},
-			Ports: []string{"54321:5432"},
-			Options: strings.Join([]string{
-				// Set health checks to wait until postgres has started
-				"--health-cmd pg_isready",
-				"--health-interval 10s",
-				"--health-timeout 5s",
-				"--health-retries 5",
-			}, " "),
-		}}
+		s, _ := core.TestServiceByName("postgres")
+		j.addService(s, version)
+		w.explainJob(sink, jobID, "provides the postgres test service because githubWorkflow.ci.postgres.enabled is set")
 	}
 	if cfg.CI.KubernetesEnvtest.Enabled {
-		testJob.addStep(jobStep{
+		w.explainJob(sink, jobID, "downloads the envtest binaries because githubWorkflow.ci.kubernetesEnvtest.enabled is set")
+	}
+	if useEnvtest {
+		j.addStep(jobStep{
 			ID:   "cache-envtest",
 			Name: "Cache envtest binaries",
 			Uses: core.CacheAction,
@@ -80,11 +257,11 @@ func ciWorkflow(cfg *core.GithubWorkflowConfiguration, hasBinaries bool) {
 			},
 		})
 		// Download the envtest binaries, in case of cache miss.
-		envtestVersion := core.DefaultK8sEnvtestVersion
+		envtestVersion := cfg.ToolVersions.Get("k8s-envtest")
 		if cfg.CI.KubernetesEnvtest.Version != "" {
 			envtestVersion = cfg.CI.KubernetesEnvtest.Version
 		}
-		testJob.addStep(jobStep{
+		j.addStep(jobStep{
 			Name: "Download envtest binaries",
 			If:   "steps.cache-envtest.outputs.cache-hit != 'true'",
 			Run: makeMultilineYAMLString([]string{
@@ -94,42 +271,6 @@ func ciWorkflow(cfg *core.GithubWorkflowConfiguration, hasBinaries bool) {
 			}),
 		})
 	}
-	testJob.addStep(jobStep{
-		Name: "Run tests and generate coverage report",
-		Run:  "make build/cover.out",
-	})
-	if cfg.CI.Coveralls && !cfg.IsSelfHostedRunner {
-		multipleOS := len(cfg.CI.RunnerType) > 1
-		env := map[string]string{
-			"GIT_BRANCH":      "${{ github.head_ref }}",
-			"COVERALLS_TOKEN": "${{ secrets.GITHUB_TOKEN }}",
-		}
-		installGoveralls := "go install github.com/mattn/goveralls@latest"
-		cmd := "goveralls -service=github -coverprofile=build/cover.out"
-		if multipleOS {
-			cmd += ` -parallel -flagname="Unit-${{ matrix.os }}"`
-		}
-		testJob.addStep(jobStep{
-			Name: "Upload coverage report to Coveralls",
-			Run:  makeMultilineYAMLString([]string{installGoveralls, cmd}),
-			Env:  env,
-		})
-
-		if multipleOS {
-			// 04. Tell Coveralls to merge coverage results.
-			finishJob := baseJobWithGo("Finish", cfg.IsSelfHostedRunner, goVersion)
-			finishJob.Needs = []string{"test"} // this is the <job_id> for the test job
-			finishJob.addStep(jobStep{
-				Name: "Coveralls post build webhook",
-				Run:  makeMultilineYAMLString([]string{installGoveralls, "goveralls -parallel-finish"}),
-				Env:  env,
-			})
-			w.Jobs["finish"] = finishJob
-		}
-	}
-	w.Jobs["test"] = testJob
-
-	writeWorkflowToFile(w)
 }
 
 func buildOrTestBaseJob(name string, isSelfHostedRunner bool, runsOnList []string, goVersion string) job {
@@ -145,3 +286,29 @@ func buildOrTestBaseJob(name string, isSelfHostedRunner bool, runsOnList []strin
 	}
 	return j
 }
+
+// addService adds a service container for the given test service to the job.
+func (j *job) addService(s core.TestService, version string) {
+	if j.Services == nil {
+		j.Services = make(map[string]jobService)
+	}
+	svc := jobService{
+		Image: fmt.Sprintf("%s:%s", s.Image, version),
+		Env:   s.ContainerEnv,
+		Ports: []string{fmt.Sprintf("%d:%d", s.HostPort, s.ContainerPort)},
+	}
+	if s.HealthCmd != "" {
+		healthCmd := s.HealthCmd
+		if strings.Contains(healthCmd, " ") {
+			healthCmd = fmt.Sprintf("%q", healthCmd)
+		}
+		svc.Options = strings.Join([]string{
+			// Set health checks to wait until the service has started
+			"--health-cmd " + healthCmd,
+			"--health-interval 10s",
+			"--health-timeout 5s",
+			"--health-retries 5",
+		}, " ")
+	}
+	j.Services[s.Name] = svc
+}
@@ -17,12 +17,18 @@ import (
 	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
-func codeQLWorkflow(cfg *core.GithubWorkflowConfiguration) {
+func codeQLWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration) {
 	w := newWorkflow("CodeQL", cfg.Global.DefaultBranch, nil)
 
-	if w.deleteIf(cfg.SecurityChecks.Enabled && !cfg.IsSelfHostedRunner) {
+	if w.deleteIf(sink, cfg.SecurityChecks.Enabled && !cfg.IsSelfHostedRunner) {
+		if cfg.SecurityChecks.Enabled {
+			w.explain(sink, "not generated because CodeQL is not available on self-hosted runners")
+		} else {
+			w.explain(sink, "not generated because githubWorkflow.securityChecks.enabled is not set")
+		}
 		return
 	}
+	w.explain(sink, "githubWorkflow.securityChecks.enabled is set")
 
 	w.Permissions.Actions = tokenScopeRead         // for github/codeql-action/init to get workflow details
 	w.Permissions.SecurityEvents = tokenScopeWrite // for github/codeql-action/analyze to upload SARIF results
@@ -50,5 +56,5 @@ func codeQLWorkflow(cfg *core.GithubWorkflowConfiguration) {
 	})
 	w.Jobs = map[string]job{"analyze": j}
 
-	writeWorkflowToFile(w)
+	writeWorkflowToFile(sink, w, cfg)
 }
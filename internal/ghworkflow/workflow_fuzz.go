@@ -0,0 +1,74 @@
+// Copyright 2023 SAP SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ghworkflow
+
+import (
+	"github.com/sapcc/go-bits/logg"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+func fuzzWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration, sr core.ScanResult) {
+	w := newWorkflow("Fuzz", cfg.Global.DefaultBranch, nil)
+
+	hasFuzzTargets := len(sr.FuzzTargets) > 0
+	if cfg.Fuzzing.Enabled && !hasFuzzTargets {
+		logg.Other("WARNING", "not generating the fuzzing workflow despite githubWorkflow.fuzzing.enabled because no fuzz tests were found")
+	}
+	if w.deleteIf(sink, cfg.Fuzzing.Enabled && hasFuzzTargets) {
+		if cfg.Fuzzing.Enabled {
+			w.explain(sink, "not generated because the test files do not contain any fuzz tests")
+		} else {
+			w.explain(sink, "not generated because githubWorkflow.fuzzing.enabled is not set")
+		}
+		return
+	}
+	w.explain(sink, "githubWorkflow.fuzzing.enabled is set and the test files contain fuzz tests")
+
+	// fuzzing takes too long for every push, so it only runs on a schedule
+	// (or when started manually)
+	w.On = eventTrigger{
+		Schedule:         []cronExpr{{Cron: "00 03 * * *"}}, // every day at 03:00 AM
+		WorkflowDispatch: workflowDispatch{manualTrigger: true},
+	}
+
+	j := baseJobWithGo("Fuzz", cfg.IsSelfHostedRunner, cfg.Global.GoVersion)
+	if sr.IsMultiModule() {
+		j.cacheAllModules()
+	}
+	// with -k, a crash in one fuzz test does not prevent the others from running
+	cmd := "make -k fuzz"
+	if cfg.Fuzzing.FuzzTime != "" {
+		cmd += " FUZZTIME=" + cfg.Fuzzing.FuzzTime
+		w.explain(sink, "each fuzz test runs for %s because githubWorkflow.fuzzing.fuzzTime is set", cfg.Fuzzing.FuzzTime)
+	}
+	j.addStep(jobStep{
+		Name: "Run fuzz tests",
+		Run:  cmd,
+	})
+	// `go test -fuzz` writes failing inputs into the corpus directory of the respective fuzz test
+	j.addStep(jobStep{
+		Name: "Upload failing inputs",
+		If:   "failure()",
+		Uses: core.UploadArtifactAction,
+		With: map[string]any{
+			"name": "fuzz-crashers",
+			"path": "**/testdata/fuzz/**",
+		},
+	})
+	w.Jobs = map[string]job{"fuzz": j}
+
+	writeWorkflowToFile(sink, w, cfg)
+}
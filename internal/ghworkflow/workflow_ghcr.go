@@ -15,13 +15,15 @@ package ghworkflow
 
 import "github.com/sapcc/go-makefile-maker/internal/core"
 
-func ghcrWorkflow(cfg *core.GithubWorkflowConfiguration) {
+func ghcrWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration) {
 	// https://docs.github.com/en/packages/managing-github-packages-using-github-actions-workflows/publishing-and-installing-a-package-with-github-actions#publishing-a-package-using-an-action
 	w := newWorkflow("Container Registry GHCR", cfg.Global.DefaultBranch, nil)
 
-	if w.deleteIf(cfg.PushContainerToGhcr.Enabled) {
+	if w.deleteIf(sink, cfg.PushContainerToGhcr.Enabled) {
+		w.explain(sink, "not generated because githubWorkflow.pushContainerToGhcr.enabled is not set")
 		return
 	}
+	w.explain(sink, "githubWorkflow.pushContainerToGhcr.enabled is set")
 
 	w.Permissions.Contents = tokenScopeRead
 	w.Permissions.Packages = tokenScopeWrite
@@ -68,5 +70,5 @@ type=semver,pattern=v{{major}}`,
 	})
 	w.Jobs = map[string]job{"build-and-push-image": j}
 
-	writeWorkflowToFile(w)
+	writeWorkflowToFile(sink, w, cfg)
 }
@@ -15,13 +15,15 @@ package ghworkflow
 
 import "github.com/sapcc/go-makefile-maker/internal/core"
 
-func releaseWorkflow(cfg *core.GithubWorkflowConfiguration) {
+func releaseWorkflow(sink core.OutputSink, cfg *core.GithubWorkflowConfiguration) {
 	// https://docs.github.com/en/packages/managing-github-packages-using-github-actions-workflows/publishing-and-installing-a-package-with-github-actions#publishing-a-package-using-an-action
 	w := newWorkflow("goreleaser", cfg.Global.DefaultBranch, nil)
 
-	if w.deleteIf(cfg.Release.Enabled) {
+	if w.deleteIf(sink, cfg.Release.Enabled) {
+		w.explain(sink, "not generated because githubWorkflow.release.enabled is not set")
 		return
 	}
+	w.explain(sink, "githubWorkflow.release.enabled is set")
 
 	w.Permissions.Contents = tokenScopeWrite
 	w.Permissions.Packages = tokenScopeWrite
@@ -56,5 +58,5 @@ func releaseWorkflow(cfg *core.GithubWorkflowConfiguration) {
 	})
 	w.Jobs = map[string]job{"release": j}
 
-	writeWorkflowToFile(w)
+	writeWorkflowToFile(sink, w, cfg)
 }
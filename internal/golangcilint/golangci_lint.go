@@ -15,8 +15,8 @@
 package golangcilint
 
 import (
+	"bytes"
 	"fmt"
-	"os"
 	"strings"
 	"text/template"
 
@@ -191,13 +191,13 @@ type configTmplData struct {
 	SkipDirs            []string
 }
 
-func RenderConfig(cfg core.GolangciLintConfiguration, vendoring bool, modulePath string, misspellIgnoreWords []string) {
+func RenderConfig(sink core.OutputSink, cfg core.GolangciLintConfiguration, vendoring bool, modulePath string, misspellIgnoreWords []string) {
 	mode := "readonly"
 	if vendoring {
 		mode = "vendor"
 	}
 
-	f := must.Return(os.Create(".golangci.yaml"))
+	f := &bytes.Buffer{}
 	fmt.Fprintln(f, core.AutogeneratedHeader+"\n")
 	must.Succeed(configTmpl.Execute(f, configTmplData{
 		ModulePath:          modulePath,
@@ -208,5 +208,5 @@ func RenderConfig(cfg core.GolangciLintConfiguration, vendoring bool, modulePath
 	}))
 	fmt.Fprintln(f) // empty line at end
 
-	must.Succeed(f.Close())
+	must.Succeed(sink.WriteFile(".golangci.yaml", f.Bytes(), 0666))
 }
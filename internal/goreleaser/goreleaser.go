@@ -20,11 +20,11 @@ package goreleaser
 
 import (
 	"fmt"
-	"os"
+	"slices"
+	"strings"
 
 	"github.com/sapcc/go-makefile-maker/internal/core"
 
-	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 )
 
@@ -33,24 +33,7 @@ const goreleaserTemplate = `before:
     - go mod tidy
 
 builds:
-  - env:
-      - CGO_ENABLED=0
-    goos:
-      - linux
-      - windows
-      - darwin
-    goarch:
-      - amd64
-      - arm64
-    ldflags:
-      - -s -w
-      - -X github.com/sapcc/go-api-declarations/bininfo.binName=%[1]s
-      - -X github.com/sapcc/go-api-declarations/bininfo.version={{ .Version }}
-      - -X github.com/sapcc/go-api-declarations/bininfo.commit={{ .FullCommit  }}
-      - -X github.com/sapcc/go-api-declarations/bininfo.buildDate={{ .CommitDate }} # use CommitDate instead of Date for reproducibility
-    # Set the modified timestamp on the output binary to ensure that builds are reproducible.
-    mod_timestamp: "{{ .CommitTimestamp }}"
-
+%[1]s
 snapshot:
   name_template: "{{ .Tag }}-next"
 
@@ -68,17 +51,134 @@ archives:
       - README.md
 `
 
-func RenderConfig(cfg core.Configuration) {
-	if len(cfg.Binaries) < 1 {
-		logg.Fatal("Goreleaser requires at least 1 binary to be configured in binaries!")
+const buildTemplate = `  - %[1]senv:
+%[2]s%[3]s    ldflags:
+      - -s -w
+      - -X github.com/sapcc/go-api-declarations/bininfo.binName=%[4]s
+      - -X github.com/sapcc/go-api-declarations/bininfo.version={{ .Version }}
+      - -X github.com/sapcc/go-api-declarations/bininfo.commit={{ .FullCommit  }}
+      - -X github.com/sapcc/go-api-declarations/bininfo.buildDate={{ .CommitDate }} # use CommitDate instead of Date for reproducibility
+%[5]s    # Set the modified timestamp on the output binary to ensure that builds are reproducible.
+    mod_timestamp: "{{ .CommitTimestamp }}"
+`
+
+// platformsWithoutCGO can be cross-compiled from any platform.
+const platformsWithoutCGO = `    goos:
+      - linux
+      - windows
+      - darwin
+    goarch:
+      - amd64
+      - arm64
+`
+
+// platformsWithCGO is restricted to the platform of the release workflow
+// because cgo requires a C toolchain for the target platform.
+const platformsWithCGO = `    # cgo requires a C toolchain for the target platform, so only the platform of the build machine is built
+    goos:
+      - linux
+    goarch:
+      - amd64
+`
+
+func RenderConfig(sink core.OutputSink, cfg core.Configuration, sr core.ScanResult) {
+	// without per-binary build settings, the first binary is built from the
+	// repository root like before; otherwise, each binary gets its own build
+	hasBuildSettings := slices.ContainsFunc(cfg.Binaries, core.BinaryConfiguration.HasBuildSettings)
+	inOtherModule := slices.ContainsFunc(cfg.Binaries, func(bin core.BinaryConfiguration) bool {
+		m := sr.ModuleForPackage(bin.FromPackage)
+		return m.Dir != "." && m.Dir != ""
+	})
+	perBinary := hasBuildSettings || inOtherModule
+
+	var builds string
+	if perBinary {
+		if hasBuildSettings {
+			core.Explain(sink, ".goreleaser.yaml", "each binary has its own build because some entries in binaries have their own build settings")
+		}
+		if inOtherModule {
+			core.Explain(sink, ".goreleaser.yaml", "each binary has its own build because some binaries are in other modules than the main module")
+		}
+		for _, bin := range cfg.Binaries {
+			builds += renderBuild(sink, cfg, sr, bin, true)
+		}
+	} else {
+		builds = renderBuild(sink, cfg, sr, cfg.Binaries[0], false)
 	}
-	if cfg.Metadata.URL == "" {
-		logg.Fatal("Goreleasre requires metadata.url to be configured!")
+	goreleaserFile := core.AutogeneratedHeader + "\n\n" + fmt.Sprintf(goreleaserTemplate, builds)
+
+	// Remove file that was renamed before core.ManifestSink took care of stale files
+	must.Succeed(sink.RemoveFile(".goreleaser.yml"))
+	must.Succeed(sink.WriteFile(".goreleaser.yaml", []byte(goreleaserFile), 0666))
+}
+
+func renderBuild(sink core.OutputSink, cfg core.Configuration, sr core.ScanResult, bin core.BinaryConfiguration, withSettings bool) string {
+	usesCGO := cfg.Golang.UsesCGO(sr)
+	if withSettings {
+		usesCGO = bin.UsesCGO(cfg.Golang, sr)
+	}
+	cgoEnabled := "0"
+	if usesCGO {
+		cgoEnabled = "1"
+	}
+	if !withSettings {
+		platforms := platformsWithoutCGO
+		if usesCGO {
+			platforms = platformsWithCGO
+			core.Explain(sink, ".goreleaser.yaml", "only linux/amd64 is built because %s", cfg.Golang.ExplainCGO(sr))
+		}
+		return fmt.Sprintf(buildTemplate, "", "      - CGO_ENABLED="+cgoEnabled+"\n", platforms, bin.Name, "")
+	}
+
+	// binaries from other modules need to be built from within that module's
+	// directory, like in the Makefile
+	header := fmt.Sprintf("id: %s\n    main: %s\n    binary: %s\n    ", bin.Name, bin.FromPackage, bin.Name)
+	if m := sr.ModuleForPackage(bin.FromPackage); m.Dir != "." && m.Dir != "" {
+		header = fmt.Sprintf("id: %s\n    dir: %s\n    main: %s\n    binary: %s\n    ",
+			bin.Name, strings.TrimPrefix(m.Dir, "./"), m.RelativePackagePath(bin.FromPackage), bin.Name)
+	}
+	env := "      - CGO_ENABLED=" + cgoEnabled + "\n"
+	for _, kv := range bin.Env {
+		if !strings.HasPrefix(kv, "CGO_ENABLED=") {
+			env += "      - " + kv + "\n"
+		}
+	}
+
+	var settings string
+	if flags := strings.Fields(bin.BuildFlags); len(flags) > 0 {
+		settings += "    flags:\n" + yamlList(flags)
+	}
+	if len(bin.Tags) > 0 {
+		settings += "    tags:\n" + yamlList(bin.Tags)
+	}
+	switch {
+	case bin.GOOS != "" || bin.GOARCH != "":
+		goos, goarch := []string{"linux", "windows", "darwin"}, []string{"amd64", "arm64"}
+		if bin.GOOS != "" {
+			goos = []string{bin.GOOS}
+		}
+		if bin.GOARCH != "" {
+			goarch = []string{bin.GOARCH}
+		}
+		settings += "    goos:\n" + yamlList(goos) + "    goarch:\n" + yamlList(goarch)
+	case usesCGO:
+		core.Explain(sink, ".goreleaser.yaml", "only linux/amd64 is built for %s because it uses cgo", bin.Name)
+		settings += platformsWithCGO
+	default:
+		settings += platformsWithoutCGO
 	}
 
-	goreleaserFile := fmt.Sprintf(goreleaserTemplate, cfg.Binaries[0].Name)
+	var ldflags string
+	if bin.Ldflags != "" {
+		ldflags = "      - " + bin.Ldflags + "\n"
+	}
+	return fmt.Sprintf(buildTemplate, header, env, settings, bin.Name, ldflags)
+}
 
-	// Remove renamed file
-	must.Succeed(os.RemoveAll(".goreleaser.yml"))
-	must.Succeed(os.WriteFile(".goreleaser.yaml", []byte(goreleaserFile), 0666))
+func yamlList(items []string) string {
+	var result string
+	for _, item := range items {
+		result += "      - " + item + "\n"
+	}
+	return result
 }
@@ -22,15 +22,23 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/sapcc/go-bits/logg"
+
 	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
 // newMakefile defines the structure of the Makefile. Order is important as categories,
 // rules, and definitions will appear in the exact order as they are defined.
-func newMakefile(cfg *core.Configuration, sr core.ScanResult) *makefile {
+func newMakefile(sink core.OutputSink, cfg *core.Configuration, sr core.ScanResult) *makefile {
 	hasBinaries := len(cfg.Binaries) > 0
+	err := checkModuleNames(sr)
+	if err != nil {
+		logg.Fatal(err.Error())
+	}
 
 	///////////////////////////////////////////////////////////////////////////
 	// General
@@ -51,12 +59,14 @@ endif
 		general.addRule(rule{
 			target:        "default",
 			prerequisites: []string{"build-all"},
+			reasons:       []string{"there are binaries, so `make` builds all of them"},
 		})
 	} else {
 		general.addRule(rule{
-			target: "default",
-			phony:  true,
-			recipe: []string{"@echo 'There is nothing to build, use `make check` for running the test suite or `make help` for a list of available targets.'"},
+			target:  "default",
+			phony:   true,
+			recipe:  []string{"@echo 'There is nothing to build, use `make check` for running the test suite or `make help` for a list of available targets.'"},
+			reasons: []string{"there are no binaries, so `make` only prints a hint"},
 		})
 	}
 
@@ -67,11 +77,26 @@ endif
 	defaultBuildFlags := ""
 	if cfg.Golang.EnableVendoring {
 		defaultBuildFlags = "-mod vendor"
+		core.Explain(sink, "Makefile", "GO_BUILDFLAGS defaults to %q because golang.enableVendoring is set", defaultBuildFlags)
 	}
 	build.addDefinition("GO_BUILDFLAGS =%s", cfg.Variable("GO_BUILDFLAGS", defaultBuildFlags))
 	build.addDefinition("GO_LDFLAGS =%s", cfg.Variable("GO_LDFLAGS", ""))
-	build.addDefinition("GO_TESTENV =%s", cfg.Variable("GO_TESTENV", ""))
+	var testEnv []string
+	for _, s := range cfg.TestServices.Services(sr) {
+		testEnv = append(testEnv, s.TestEnv...)
+		if len(s.TestEnv) > 0 {
+			core.Explain(sink, "Makefile", "GO_TESTENV contains the connection variables for the %s test service because go.mod requires %s", s.Name, strings.Join(s.Modules, " or "))
+		}
+	}
+	build.addDefinition("GO_TESTENV =%s", cfg.Variable("GO_TESTENV", strings.Join(testEnv, " ")))
+	if cgoEnabled := cfg.Golang.CGOEnabled(sr); cgoEnabled != "" {
+		core.Explain(sink, "Makefile", "CGO_ENABLED defaults to %s because %s", cgoEnabled, cfg.Golang.ExplainCGO(sr))
+		build.addDefinition("export CGO_ENABLED ?= %s", cgoEnabled)
+	} else {
+		core.Explain(sink, "Makefile", "CGO_ENABLED is left to the go command because %s", cfg.Golang.ExplainCGO(sr))
+	}
 	if sr.HasBinInfo {
+		core.Explain(sink, "Makefile", "the BININFO_* variables are defined because go.mod requires github.com/sapcc/go-api-declarations v1.2.0 or newer")
 		build.addDefinition("")
 		build.addDefinition("# These definitions are overridable, e.g. to provide fixed version/commit values when")
 		build.addDefinition("# no .git directory is present or to provide a fixed build date for reproducability.")
@@ -81,7 +106,10 @@ endif
 	}
 
 	if hasBinaries {
-		build.addRule(buildTargets(cfg.Binaries, sr)...)
+		build.addRule(buildTargets(cfg, sr)...)
+		if len(cfg.CrossCompile) > 0 {
+			build.addRule(crossTargets(cfg, sr)...)
+		}
 		if r, ok := installTarget(cfg.Binaries); ok {
 			build.addRule(r)
 		}
@@ -91,7 +119,6 @@ endif
 	// Test
 	test := category{name: "test"}
 
-	test.addDefinition(`# which packages to test with "go test"`)
 	testPkgGreps := ""
 	if cfg.Test.Only != "" {
 		testPkgGreps += fmt.Sprintf(" | grep -E '%s'", cfg.Test.Only)
@@ -99,9 +126,6 @@ endif
 	if cfg.Test.Except != "" {
 		testPkgGreps += fmt.Sprintf(" | grep -Ev '%s'", cfg.Test.Except)
 	}
-	test.addDefinition(`GO_TESTPKGS := $(shell go list -f '{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}' ./...%s)`, testPkgGreps)
-
-	test.addDefinition(`# which packages to measure coverage for`)
 	coverPkgGreps := ""
 	if cfg.Coverage.Only != "" {
 		coverPkgGreps += fmt.Sprintf(" | grep -E '%s'", cfg.Coverage.Only)
@@ -109,7 +133,47 @@ endif
 	if cfg.Coverage.Except != "" {
 		coverPkgGreps += fmt.Sprintf(" | grep -Ev '%s'", cfg.Coverage.Except)
 	}
-	test.addDefinition(`GO_COVERPKGS := $(shell go list ./...%s)`, coverPkgGreps)
+
+	// For repositories with multiple modules, each module gets its own package lists
+	// because `go list ./...` does not descend into other modules.
+	test.addDefinition(`# which packages to test with "go test"`)
+	for _, m := range sr.Modules {
+		test.addDefinition(`%s := $(shell %s)`, moduleVarName("GO_TESTPKGS", m), inModuleDir(m,
+			fmt.Sprintf(`go list -f '{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}' ./...%s`, testPkgGreps)))
+	}
+	if len(cfg.TestGroups) > 0 {
+		test.addDefinition(`# which packages to test in each test group`)
+		for _, tg := range cfg.TestGroups {
+			groupPkgGreps := testPkgGreps
+			if tg.Only != "" {
+				groupPkgGreps += fmt.Sprintf(" | grep -E '%s'", tg.Only)
+			}
+			if tg.Except != "" {
+				groupPkgGreps += fmt.Sprintf(" | grep -Ev '%s'", tg.Except)
+			}
+			test.addDefinition(`%s := $(shell go list -f '{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}' ./...%s)`,
+				testGroupVarName(tg), groupPkgGreps)
+		}
+	}
+	test.addDefinition(`# which packages to measure coverage for`)
+	for _, m := range sr.Modules {
+		test.addDefinition(`%s := $(shell %s)`, moduleVarName("GO_COVERPKGS", m), inModuleDir(m,
+			fmt.Sprintf(`go list ./...%s`, coverPkgGreps)))
+	}
+	if cfg.Benchmarks.Enabled {
+		benchPkgGreps := ""
+		if cfg.Benchmarks.Only != "" {
+			benchPkgGreps += fmt.Sprintf(" | grep -E '%s'", cfg.Benchmarks.Only)
+		}
+		if cfg.Benchmarks.Except != "" {
+			benchPkgGreps += fmt.Sprintf(" | grep -Ev '%s'", cfg.Benchmarks.Except)
+		}
+		test.addDefinition(`# which packages to benchmark with "go test -bench"`)
+		for _, m := range sr.Modules {
+			test.addDefinition(`%s := $(shell %s)`, moduleVarName("GO_BENCHPKGS", m), inModuleDir(m,
+				fmt.Sprintf(`go list -f '{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}' ./...%s`, benchPkgGreps)))
+		}
+	}
 	test.addDefinition(`# to get around weird Makefile syntax restrictions, we need variables containing nothing, a space and comma`)
 	test.addDefinition(`null :=`)
 	test.addDefinition(`space := $(null) $(null)`)
@@ -118,9 +182,22 @@ endif
 	//add main testing target
 	var checkPrerequisites []string
 	if hasBinaries {
-		checkPrerequisites = []string{"build-all", "static-check", "build/cover.html"}
+		checkPrerequisites = []string{"build-all", "static-check"}
 	} else {
-		checkPrerequisites = []string{"static-check", "build/cover.html"}
+		checkPrerequisites = []string{"static-check"}
+	}
+	var checkReasons []string
+	if slices.ContainsFunc(cfg.TestGroups, func(tg core.TestGroup) bool { return tg.SkipInCheck }) {
+		// the coverage report would require all groups, so only the tests are run
+		for _, tg := range cfg.TestGroups {
+			if tg.SkipInCheck {
+				checkReasons = append(checkReasons, fmt.Sprintf("does not run test-%s because testGroups.skipInCheck is set for it", tg.Name))
+			} else {
+				checkPrerequisites = append(checkPrerequisites, "test-"+tg.Name)
+			}
+		}
+	} else {
+		checkPrerequisites = append(checkPrerequisites, "build/cover.html")
 	}
 	test.addRule(rule{
 		description:   "Run the test suite (unit tests and golangci-lint).",
@@ -128,6 +205,7 @@ endif
 		target:        "check",
 		prerequisites: checkPrerequisites,
 		recipe:        []string{`@printf "\e[1;32m>> All checks successful.\e[0m\n"`},
+		reasons:       checkReasons,
 	})
 
 	//add target for installing dependencies for `make check`
@@ -148,27 +226,124 @@ endif
 		phony:         true,
 		target:        "static-check",
 		prerequisites: []string{"prepare-static-check"},
-		recipe: []string{
-			`@printf "\e[1;36m>> golangci-lint\e[0m\n"`,
-			`@golangci-lint run`,
-		},
+		recipe: append(
+			[]string{`@printf "\e[1;36m>> golangci-lint\e[0m\n"`},
+			inEachModuleDir(sr, "@", "golangci-lint run")...,
+		),
 	})
 
 	//add targets for `go test` incl. coverage report
-	test.addRule(rule{
-		description: "Run tests and generate coverage report.",
-		phony:       true,
-		target:      "build/cover.out",
-		// We use order only prerequisite because this target is used in CI.
-		orderOnlyPrerequisites: []string{"build"},
-		recipe: []string{
-			`@printf "\e[1;36m>> go test\e[0m\n"`,
-			fmt.Sprintf(
-				`@env $(GO_TESTENV) go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' -shuffle=on -p 1 -coverprofile=$@ -covermode=count -coverpkg=$(subst $(space),$(comma),$(GO_COVERPKGS)) $(GO_TESTPKGS)`,
-				makeDefaultLinkerFlags(path.Base(sr.MustModulePath()), sr),
-			),
-		},
-	})
+	testLinkerFlags := makeDefaultLinkerFlags(path.Base(sr.MustModulePath()), sr)
+	testFlags, testEnvArgs := cfg.GoTest.Flags(), "$(GO_TESTENV)"
+	if cfg.GoTest.Race {
+		// the race detector requires cgo
+		testFlags += " -race"
+		testEnvArgs += " CGO_ENABLED=1"
+		core.Explain(sink, "Makefile:build/cover.out", "the tests run with the race detector because goTest.race is set")
+	}
+	coverMode := cfg.GoTest.CoverMode()
+	switch {
+	case len(cfg.TestGroups) > 0:
+		mergeRule := rule{
+			description:            "Run tests for all test groups and generate a merged coverage report.",
+			phony:                  true,
+			target:                 "build/cover.out",
+			orderOnlyPrerequisites: []string{"build"},
+			reasons:                []string{"testGroups is set, so the coverage reports of all test groups are merged"},
+		}
+		var groupRules []rule
+		var coverFiles []string
+		for _, tg := range cfg.TestGroups {
+			groupEnvArgs := testEnvArgs
+			for _, kv := range tg.Env {
+				groupEnvArgs += " " + kv
+			}
+			groupFlags := cfg.GoTest.ForGroup(tg).Flags()
+			if cfg.GoTest.Race {
+				groupFlags += " -race"
+			}
+			coverFile := fmt.Sprintf("build/cover-%s.out", tg.Name)
+			groupRules = append(groupRules, rule{
+				description:            fmt.Sprintf("Run tests for the %s test group and generate its coverage report in %s.", tg.Name, coverFile),
+				phony:                  true,
+				target:                 "test-" + tg.Name,
+				orderOnlyPrerequisites: []string{"build"},
+				reasons:                []string{fmt.Sprintf("testGroups contains an entry for %s", tg.Name)},
+				recipe: []string{
+					fmt.Sprintf(`@printf "\e[1;36m>> go test (%s)\e[0m\n"`, tg.Name),
+					fmt.Sprintf(
+						`@env %s go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s -coverprofile=%s -covermode=%s -coverpkg=$(subst $(space),$(comma),$(GO_COVERPKGS)) $(%s)`,
+						groupEnvArgs, testLinkerFlags, groupFlags, coverFile, coverMode, testGroupVarName(tg),
+					),
+				},
+			})
+			mergeRule.prerequisites = append(mergeRule.prerequisites, "test-"+tg.Name)
+			coverFiles = append(coverFiles, coverFile)
+		}
+		mergeRule.recipe = []string{
+			`@printf "\e[1;36m>> merging coverage reports\e[0m\n"`,
+			fmt.Sprintf(`@echo 'mode: %s' > $@`, coverMode),
+			fmt.Sprintf(`@awk 'FNR > 1' %s >> $@`, strings.Join(coverFiles, " ")),
+		}
+		test.addRule(mergeRule)
+		test.addRule(groupRules...)
+	case sr.IsMultiModule():
+		mergeRule := rule{
+			description:            "Run tests for all modules and generate a merged coverage report.",
+			phony:                  true,
+			target:                 "build/cover.out",
+			orderOnlyPrerequisites: []string{"build"},
+			reasons:                []string{"the repository contains multiple modules, so the coverage reports of all modules are merged"},
+		}
+		var moduleRules []rule
+		var coverFiles []string
+		for _, m := range sr.Modules {
+			desc := fmt.Sprintf("Run tests for the module in %s and generate its coverage report.", m.Dir)
+			if m.Dir == "." {
+				desc = "Run tests for the main module and generate its coverage report."
+			}
+			r := rule{
+				description: desc,
+				phony:       true,
+				target:      moduleCoverFile(m),
+				// We use order only prerequisite because this target is used in CI.
+				orderOnlyPrerequisites: []string{"build"},
+				reasons:                []string{fmt.Sprintf("the repository contains multiple modules, and this one is in %s", m.Dir)},
+				recipe: []string{
+					fmt.Sprintf(`@printf "\e[1;36m>> go test %s\e[0m\n"`, m.Dir),
+					"@" + inModuleDir(m, fmt.Sprintf(
+						`env %s go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s -coverprofile=$(CURDIR)/$@ -covermode=%s -coverpkg=$(subst $(space),$(comma),$(%s)) $(%s)`,
+						testEnvArgs, testLinkerFlags, testFlags, coverMode, moduleVarName("GO_COVERPKGS", m), moduleVarName("GO_TESTPKGS", m),
+					)),
+				},
+			}
+			moduleRules = append(moduleRules, r)
+			coverFiles = append(coverFiles, r.target)
+		}
+		mergeRule.prerequisites = coverFiles
+		mergeRule.recipe = []string{
+			`@printf "\e[1;36m>> merging coverage reports\e[0m\n"`,
+			fmt.Sprintf(`@echo 'mode: %s' > $@`, coverMode),
+			fmt.Sprintf(`@awk 'FNR > 1' %s >> $@`, strings.Join(coverFiles, " ")),
+		}
+		test.addRule(mergeRule)
+		test.addRule(moduleRules...)
+	default:
+		test.addRule(rule{
+			description: "Run tests and generate coverage report.",
+			phony:       true,
+			target:      "build/cover.out",
+			// We use order only prerequisite because this target is used in CI.
+			orderOnlyPrerequisites: []string{"build"},
+			recipe: []string{
+				`@printf "\e[1;36m>> go test\e[0m\n"`,
+				fmt.Sprintf(
+					`@env %s go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s -coverprofile=$@ -covermode=%s -coverpkg=$(subst $(space),$(comma),$(GO_COVERPKGS)) $(GO_TESTPKGS)`,
+					testEnvArgs, testLinkerFlags, testFlags, coverMode,
+				),
+			},
+		})
+	}
 
 	test.addRule(rule{
 		description:   "Generate an HTML file with source code annotations from the coverage report.",
@@ -180,6 +355,45 @@ endif
 		},
 	})
 
+	//add target for running the tests with the race detector (without coverage
+	//instrumentation, since there is no report to write it into)
+	raceRule := rule{
+		description: "Run the tests with the race detector.",
+		phony:       true,
+		target:      "check-race",
+		recipe:      []string{`@printf "\e[1;36m>> go test -race\e[0m\n"`},
+	}
+	if len(cfg.TestGroups) > 0 {
+		// each group needs its own tags and env to find all of its tests
+		raceRule.reasons = append(raceRule.reasons, "testGroups is set, so the tests of each group are run with its own flags and env")
+		for _, tg := range cfg.TestGroups {
+			groupEnvArgs := "$(GO_TESTENV)"
+			for _, kv := range tg.Env {
+				groupEnvArgs += " " + kv
+			}
+			raceRule.addRecipe(
+				`@env %s CGO_ENABLED=1 go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s -race $(%s)`,
+				groupEnvArgs, testLinkerFlags, cfg.GoTest.ForGroup(tg).Flags(), testGroupVarName(tg),
+			)
+		}
+	} else {
+		for _, m := range sr.Modules {
+			// the race detector requires cgo
+			raceRule.recipe = append(raceRule.recipe, "@"+inModuleDir(m, fmt.Sprintf(
+				`env $(GO_TESTENV) CGO_ENABLED=1 go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s -race $(%s)`,
+				testLinkerFlags, cfg.GoTest.Flags(), moduleVarName("GO_TESTPKGS", m),
+			)))
+		}
+	}
+	test.addRule(raceRule)
+
+	if cfg.Benchmarks.Enabled {
+		test.addRule(benchTargets(cfg, sr, testLinkerFlags)...)
+	}
+	if len(sr.FuzzTargets) > 0 {
+		test.addRule(fuzzTargets(cfg, sr)...)
+	}
+
 	///////////////////////////////////////////////////////////////////////////
 	// Development
 	dev := category{name: "development"}
@@ -196,31 +410,23 @@ endif
 			description: "Run go mod tidy, go mod verify, and go mod vendor.",
 			target:      "vendor",
 			phony:       true,
-			recipe: []string{
-				"go mod tidy",
-				"go mod vendor",
-				"go mod verify",
-			},
+			reasons:     []string{"golang.enableVendoring is set"},
+			recipe:      vendorRecipe(sr, "go mod tidy"),
 		})
 		dev.addRule(rule{
 			description: "Same as 'make vendor' but go mod tidy will use '-compat' flag with the Go version from go.mod file as value.",
 			target:      "vendor-compat",
 			phony:       true,
-			recipe: []string{
-				`go mod tidy -compat=$(shell awk '$$1 == "go" { print $$2 }' < go.mod)`,
-				"go mod vendor",
-				"go mod verify",
-			},
+			reasons:     []string{"golang.enableVendoring is set"},
+			recipe:      vendorRecipe(sr, `go mod tidy -compat=$(shell awk '$$1 == "go" { print $$2 }' < %sgo.mod)`),
 		})
 	} else {
 		dev.addRule(rule{
 			description: "Run go mod tidy and go mod verify.",
 			target:      "tidy-deps",
 			phony:       true,
-			recipe: []string{
-				"go mod tidy",
-				"go mod verify",
-			},
+			reasons:     []string{"golang.enableVendoring is not set (otherwise, `make vendor` would be generated instead)"},
+			recipe:      inEachModuleDir(sr, "", "go mod tidy", "go mod verify"),
 		})
 	}
 
@@ -234,6 +440,7 @@ endif
 			description: "Add license headers to all .go files excluding the vendor directory.",
 			target:      "license-headers",
 			phony:       true,
+			reasons:     []string{fmt.Sprintf("the module path %q belongs to an SAP organization (github.com/sapcc, github.wdf.sap.corp or github.tools.sap)", sr.ModulePath)},
 			recipe: []string{
 				`@if ! hash addlicense 2>/dev/null; then printf "\e[1;36m>> Installing addlicense...\e[0m\n"; go install github.com/google/addlicense@latest; fi`,
 				fmt.Sprintf(`find * \( -name vendor -type d -prune \) -o %[1]s\( -name \*.go -exec addlicense -c "SAP SE" -- {} + \)`, pruneFlags),
@@ -259,11 +466,107 @@ endif
 	}
 }
 
-func buildTargets(binaries []core.BinaryConfiguration, sr core.ScanResult) []rule {
+// benchTargets returns the rules for running the benchmarks and comparing
+// their results against the committed baseline.
+func benchTargets(cfg *core.Configuration, sr core.ScanResult, linkerFlags string) []rule {
+	baseline := cfg.Benchmarks.BaselinePath()
+	benchRecipe := []string{
+		`@printf "\e[1;36m>> go test -bench\e[0m\n"`,
+		`@mkdir -p build/bench && rm -f build/bench/current.txt`,
+	}
+	for _, m := range sr.Modules {
+		// the output needs to go into $(CURDIR) since inModuleDir() changes the working directory
+		benchRecipe = append(benchRecipe, "@set -o pipefail && "+inModuleDir(m, fmt.Sprintf(
+			`env $(GO_TESTENV) go test $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' %s $(%s) | tee -a $(CURDIR)/build/bench/current.txt`,
+			linkerFlags, cfg.Benchmarks.Flags(), moduleVarName("GO_BENCHPKGS", m),
+		)))
+	}
+	reasons := []string{"benchmarks.enabled is set"}
+
+	return []rule{
+		{
+			description:            "Run the benchmarks and store the results in build/bench/current.txt.",
+			phony:                  true,
+			target:                 "bench",
+			orderOnlyPrerequisites: []string{"build"},
+			reasons:                reasons,
+			recipe:                 benchRecipe,
+		},
+		{
+			description:   fmt.Sprintf("Compare the benchmark results against the baseline in %s using benchstat. Without a baseline, only the results are reported.", baseline),
+			phony:         true,
+			target:        "bench-compare",
+			prerequisites: []string{"bench"},
+			reasons:       reasons,
+			recipe: []string{
+				`@if ! hash benchstat 2>/dev/null; then printf "\e[1;36m>> Installing benchstat...\e[0m\n"; go install golang.org/x/perf/cmd/benchstat@latest; fi`,
+				// without a baseline (e.g. before the first `make bench-baseline`), benchstat just summarizes the current results
+				fmt.Sprintf(`@set -o pipefail && if [ -f %[1]s ]; then benchstat %[1]s build/bench/current.txt; else printf "\e[1;33m>> %[1]s does not exist, so the results are not compared (run \"make bench-baseline\" to create it)\e[0m\n" >&2; benchstat build/bench/current.txt; fi | tee build/bench/compare.txt`, baseline),
+			},
+		},
+		{
+			description:   fmt.Sprintf("Run the benchmarks and store the results as the new baseline in %s.", baseline),
+			phony:         true,
+			target:        "bench-baseline",
+			prerequisites: []string{"bench"},
+			reasons:       reasons,
+			recipe: []string{
+				fmt.Sprintf(`@mkdir -p %s`, path.Dir(baseline)),
+				fmt.Sprintf(`@cp build/bench/current.txt %s`, baseline),
+				fmt.Sprintf(`@printf "\e[1;32m>> Updated %s, please commit it.\e[0m\n"`, baseline),
+			},
+		},
+	}
+}
+
+// fuzzTargets returns the rules for running the fuzz tests that were found in
+// the repository, both individually and all at once.
+func fuzzTargets(cfg *core.Configuration, sr core.ScanResult) []rule {
+	allRule := rule{
+		description: "Run each fuzz test for the duration in FUZZTIME (e.g. 'make fuzz FUZZTIME=10m').",
+		phony:       true,
+		target:      "fuzz",
+		reasons:     []string{"the test files contain fuzz tests"},
+	}
+	allRule.addDefinition(`# how long each fuzz test runs, see "go help testflag" for the format`)
+	allRule.addDefinition(`FUZZTIME ?= %s`, cfg.Fuzzing.FuzzTimeOrDefault())
+
+	var fuzzRules []rule
+	for _, t := range sr.FuzzTargets {
+		target := path.Join("fuzz", t.Module.Dir, t.Package, t.Name)
+		// `go test -fuzz` keeps its generated corpus in the build cache, but it is
+		// more useful next to the seed corpus where it can be committed
+		cacheDir := fmt.Sprintf("$$(go env GOCACHE)/fuzz/%s/%s", t.ImportPath(), t.Name)
+		fuzzRules = append(fuzzRules, rule{
+			description: fmt.Sprintf("Run %s in %s and add new inputs to its corpus in %s.", t.Name, path.Join(t.Module.Dir, t.Package), path.Join(t.Module.Dir, t.CorpusDir())),
+			phony:       true,
+			target:      target,
+			reasons:     []string{fmt.Sprintf("%s is a fuzz test in %s", t.Name, t.ImportPath())},
+			recipe: []string{
+				fmt.Sprintf(`@printf "\e[1;36m>> go test -fuzz %s %s\e[0m\n"`, t.Name, t.ImportPath()),
+				"@" + inModuleDir(t.Module, fmt.Sprintf(
+					`env $(GO_TESTENV) go test $(GO_BUILDFLAGS) -run '^$$' -fuzz '^%s$$' -fuzztime $(FUZZTIME) %s`,
+					t.Name, t.Package,
+				)),
+				"@" + inModuleDir(t.Module, fmt.Sprintf(
+					`if [ -d "%[1]s" ]; then mkdir -p %[2]s && cp -R "%[1]s/." %[2]s/; fi`,
+					cacheDir, t.CorpusDir(),
+				)),
+			},
+		})
+		allRule.prerequisites = append(allRule.prerequisites, target)
+	}
+
+	return append([]rule{allRule}, fuzzRules...)
+}
+
+func buildTargets(cfg *core.Configuration, sr core.ScanResult) []rule {
+	binaries := cfg.Binaries
 	result := make([]rule, 0, len(binaries)+1)
 	bAllRule := rule{
 		description: "Build all binaries.",
 		target:      "build-all",
+		reasons:     []string{"there are binaries"},
 	}
 	result = append(result, bAllRule)
 
@@ -273,12 +576,15 @@ func buildTargets(binaries []core.BinaryConfiguration, sr core.ScanResult) []rul
 			description: fmt.Sprintf("Build %s.", bin.Name),
 			phony:       true,
 			target:      fmt.Sprintf("build/%s", bin.Name),
-			recipe: []string{fmt.Sprintf(
-				"go build $(GO_BUILDFLAGS) -ldflags '%s $(GO_LDFLAGS)' -o build/%s %s",
-				makeDefaultLinkerFlags(bin.Name, sr),
-				bin.Name, bin.FromPackage,
-			)},
+			reasons:     []string{binaryReason(cfg, bin)},
+		}
+		if sr.HasBinInfo {
+			r.reasons = append(r.reasons, "the bininfo variables are set via -ldflags because go.mod requires github.com/sapcc/go-api-declarations v1.2.0 or newer")
+		}
+		if bin.HasBuildSettings() {
+			r.reasons = append(r.reasons, fmt.Sprintf("the entry for %s in binaries has its own build settings", bin.Name))
 		}
+		r.addRecipe(goBuildRecipe(bin, sr, r.target))
 
 		result = append(result, r)
 		allPrerequisites = append(allPrerequisites, r.target)
@@ -288,6 +594,95 @@ func buildTargets(binaries []core.BinaryConfiguration, sr core.ScanResult) []rul
 	return result
 }
 
+// crossTargets returns the build-cross target and the targets for building
+// each binary for each platform in cfg.CrossCompile.
+func crossTargets(cfg *core.Configuration, sr core.ScanResult) []rule {
+	bCrossRule := rule{
+		description: "Build all binaries for all platforms in crossCompile.",
+		phony:       true,
+		target:      "build-cross",
+		reasons:     []string{fmt.Sprintf("crossCompile contains %s", strings.Join(cfg.CrossCompile, ", "))},
+	}
+	result := []rule{bCrossRule}
+
+	for _, bin := range cfg.Binaries {
+		if bin.GOOS != "" || bin.GOARCH != "" {
+			result[0].reasons = append(result[0].reasons, fmt.Sprintf("%s is left out because it is only built for its own goos/goarch", bin.Name))
+		}
+	}
+
+	for _, platform := range cfg.CrossCompile {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		for _, bin := range cfg.Binaries {
+			if bin.GOOS != "" || bin.GOARCH != "" {
+				// this binary is only built for its own platform
+				continue
+			}
+			fileName := bin.Name
+			if goos == "windows" {
+				fileName += ".exe"
+			}
+			r := rule{
+				phony:   true,
+				target:  fmt.Sprintf("build/%s_%s/%s", goos, goarch, fileName),
+				reasons: []string{fmt.Sprintf("crossCompile contains %s, and %s", platform, binaryReason(cfg, bin))},
+			}
+			bin.GOOS, bin.GOARCH = goos, goarch
+			if cfg.Golang.UsesCGO(sr) && !bin.SetsCGOEnabled() {
+				// cross-compiling with cgo requires a C toolchain for the target platform
+				bin.Env = append([]string{"CGO_ENABLED=0"}, bin.Env...)
+				r.reasons = append(r.reasons, "cgo is disabled because it would require a C toolchain for the target platform, unless the binary sets CGO_ENABLED=1 in its env")
+			}
+			r.addRecipe(goBuildRecipe(bin, sr, r.target))
+
+			result = append(result, r)
+			result[0].prerequisites = append(result[0].prerequisites, r.target)
+		}
+	}
+
+	return result
+}
+
+// goBuildRecipe returns the recipe line for building the given binary into the given path.
+func goBuildRecipe(bin core.BinaryConfiguration, sr core.ScanResult, outputPath string) string {
+	// binaries from other modules need to be built from within that module's directory
+	m := sr.ModuleForPackage(bin.FromPackage)
+	if m.Dir == "." || m.Dir == "" {
+		return goBuildCommand(bin, sr, outputPath, bin.FromPackage)
+	}
+	return inModuleDir(m, goBuildCommand(bin, sr,
+		"$(CURDIR)/"+outputPath,
+		m.RelativePackagePath(bin.FromPackage),
+	))
+}
+
+// goBuildCommand returns the `go build` command for the given binary,
+// including its per-binary build settings.
+func goBuildCommand(bin core.BinaryConfiguration, sr core.ScanResult, outputPath, pkg string) string {
+	var env, flags, ldflags string
+	for _, kv := range bin.BuildEnv() {
+		env += kv + " "
+	}
+	if bin.BuildFlags != "" {
+		flags += " " + bin.BuildFlags
+	}
+	if len(bin.Tags) > 0 {
+		flags += " -tags " + strings.Join(bin.Tags, ",")
+	}
+	if bin.Ldflags != "" {
+		ldflags = " " + bin.Ldflags
+	}
+	return fmt.Sprintf("%sgo build $(GO_BUILDFLAGS)%s -ldflags '%s%s $(GO_LDFLAGS)' -o %s %s",
+		env, flags, makeDefaultLinkerFlags(bin.Name, sr), ldflags, outputPath, pkg)
+}
+
+func binaryReason(cfg *core.Configuration, bin core.BinaryConfiguration) string {
+	if cfg.AutoDetectBinaries {
+		return fmt.Sprintf("binaries is set to auto, and %s is a main package", bin.FromPackage)
+	}
+	return fmt.Sprintf("binaries contains an entry for %s", bin.Name)
+}
+
 func makeDefaultLinkerFlags(binaryName string, sr core.ScanResult) string {
 	flags := "-s -w"
 
@@ -321,6 +716,7 @@ endif
 
 	for _, bin := range binaries {
 		if bin.InstallTo != "" {
+			r.reasons = append(r.reasons, fmt.Sprintf("binaries contains an entry for %s with installTo set", bin.Name))
 			r.prerequisites = append(r.prerequisites, fmt.Sprintf("build/%s", bin.Name))
 			// stupid MacOS does not have -D
 			r.recipe = append(r.recipe, fmt.Sprintf(
@@ -338,3 +734,104 @@ endif
 
 	return r, true
 }
+
+///////////////////////////////////////////////////////////////////////////////
+// Helper functions for multi-module repositories
+
+// moduleVarName returns the name of the variable that holds the given value
+// for the given module, e.g. "GO_TESTPKGS" for the main module and
+// "GO_TESTPKGS_API" for the module in "./api".
+func moduleVarName(baseName string, m core.ModuleInfo) string {
+	if m.Dir == "." {
+		return baseName
+	}
+	suffix := strings.ToUpper(nonIdentifierRx.ReplaceAllString(strings.TrimPrefix(m.Dir, "./"), "_"))
+	return baseName + "_" + suffix
+}
+
+var nonIdentifierRx = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// testGroupVarName returns the name of the variable that holds the packages
+// of the given test group, e.g. "GO_TESTPKGS_INTEGRATION".
+func testGroupVarName(tg core.TestGroup) string {
+	return "GO_TESTPKGS_" + strings.ToUpper(nonIdentifierRx.ReplaceAllString(tg.Name, "_"))
+}
+
+// moduleCoverFile returns the path of the coverage report for the given
+// module, e.g. "build/cover-root.out" or "build/cover-api.out".
+func moduleCoverFile(m core.ModuleInfo) string {
+	slug := "root"
+	if m.Dir != "." {
+		slug = nonIdentifierRx.ReplaceAllString(strings.TrimPrefix(m.Dir, "./"), "-")
+	}
+	return fmt.Sprintf("build/cover-%s.out", slug)
+}
+
+// checkModuleNames returns an error if two modules would share the same
+// variable names or coverage report in the Makefile, e.g. "./api-v2" and
+// "./api_v2".
+func checkModuleNames(sr core.ScanResult) error {
+	varNameOwners := make(map[string]string)
+	coverFileOwners := make(map[string]string)
+	for _, m := range sr.Modules {
+		varName := moduleVarName("GO_TESTPKGS", m)
+		if other, exists := varNameOwners[varName]; exists {
+			return fmt.Errorf("modules in %s and %s cannot be told apart in the Makefile because both would use the variable %s", other, m.Dir, varName)
+		}
+		varNameOwners[varName] = m.Dir
+
+		coverFile := moduleCoverFile(m)
+		if other, exists := coverFileOwners[coverFile]; exists {
+			return fmt.Errorf("modules in %s and %s cannot be told apart in the Makefile because both would write the coverage report %s", other, m.Dir, coverFile)
+		}
+		coverFileOwners[coverFile] = m.Dir
+	}
+	return nil
+}
+
+// inModuleDir prefixes the given shell command with a `cd` into the module
+// directory, unless it is the main module.
+func inModuleDir(m core.ModuleInfo, cmd string) string {
+	if m.Dir == "." {
+		return cmd
+	}
+	return fmt.Sprintf("cd %s && %s", strings.TrimPrefix(m.Dir, "./"), cmd)
+}
+
+// inEachModuleDir returns recipe lines that run the given commands in each
+// module, with the given recipe prefix (e.g. "@").
+func inEachModuleDir(sr core.ScanResult, prefix string, cmds ...string) []string {
+	var result []string
+	for _, m := range sr.Modules {
+		for _, cmd := range cmds {
+			result = append(result, prefix+inModuleDir(m, cmd))
+		}
+	}
+	return result
+}
+
+// vendorRecipe returns the recipe for `make vendor`. The tidyCmd may contain
+// a %s placeholder for the module directory prefix (e.g. "api/").
+func vendorRecipe(sr core.ScanResult, tidyCmd string) []string {
+	var result []string
+	for _, m := range sr.Modules {
+		cmd := tidyCmd
+		if strings.Contains(cmd, "%s") {
+			dirPrefix := ""
+			if m.Dir != "." {
+				dirPrefix = strings.TrimPrefix(m.Dir, "./") + "/"
+			}
+			cmd = fmt.Sprintf(cmd, dirPrefix)
+		}
+		result = append(result, inModuleDir(m, cmd))
+		if !sr.HasGoWork {
+			result = append(result, inModuleDir(m, "go mod vendor"))
+		}
+	}
+	if sr.HasGoWork {
+		// in workspace mode, there is only one vendor directory for all modules
+		result = append(result, "go work vendor")
+	}
+	result = append(result, inEachModuleDir(sr, "", "go mod verify")...)
+	return result
+}
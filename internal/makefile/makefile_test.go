@@ -0,0 +1,49 @@
+/******************************************************************************
+*
+*  Copyright 2020 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package makefile
+
+import (
+	"testing"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+)
+
+func TestCheckModuleNames(t *testing.T) {
+	testCases := []struct {
+		Dirs     []string
+		Expected string
+	}{
+		{[]string{".", "./api", "./api/v2"}, ""},
+		{[]string{".", "./api-v2", "./api_v2"}, "modules in ./api-v2 and ./api_v2 cannot be told apart in the Makefile because both would use the variable GO_TESTPKGS_API_V2"},
+		{[]string{".", "./root"}, "modules in . and ./root cannot be told apart in the Makefile because both would write the coverage report build/cover-root.out"},
+	}
+	for _, tc := range testCases {
+		var sr core.ScanResult
+		for _, dir := range tc.Dirs {
+			sr.Modules = append(sr.Modules, core.ModuleInfo{Dir: dir})
+		}
+		actual := ""
+		if err := checkModuleNames(sr); err != nil {
+			actual = err.Error()
+		}
+		if actual != tc.Expected {
+			t.Errorf("expected %q for %v, but got %q", tc.Expected, tc.Dirs, actual)
+		}
+	}
+}
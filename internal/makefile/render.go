@@ -15,10 +15,10 @@
 package makefile
 
 import (
-	_ "embed"
+	"bytes"
+	"embed"
 	"fmt"
 	"io"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -28,18 +28,19 @@ import (
 	"github.com/sapcc/go-makefile-maker/internal/core"
 )
 
-//go:embed with-postgres-db.sh
-var withPostgresDBScript []byte
+// testServiceScripts contains the helper script for each core.TestService.
+//
+//go:embed with-*.sh
+var testServiceScripts embed.FS
 
 // Render renders the Makefile.
-func Render(cfg *core.Configuration, sr core.ScanResult) {
-	f := must.Return(os.Create("Makefile"))
-	defer f.Close()
+func Render(sink core.OutputSink, cfg *core.Configuration, sr core.ScanResult) {
+	f := &bytes.Buffer{}
 
 	fmt.Fprintln(f, core.AutogeneratedHeader)
 	fmt.Fprintln(f)
 
-	m := newMakefile(cfg, sr)
+	m := newMakefile(sink, cfg, sr)
 	for _, c := range m.categories {
 		// Render category definitions.
 		for _, def := range c.definitions {
@@ -52,6 +53,7 @@ func Render(cfg *core.Configuration, sr core.ScanResult) {
 
 		// Render category rules.
 		for _, r := range c.rules {
+			explainRule(sink, r)
 			r.render(f)
 			// Put an empty line between rules.
 			fmt.Fprintln(f)
@@ -69,13 +71,40 @@ func Render(cfg *core.Configuration, sr core.ScanResult) {
 	m.help().render(f)
 	fmt.Fprintln(f)
 	fmt.Fprintln(f, ".PHONY: FORCE")
+	must.Succeed(sink.WriteFile("Makefile", f.Bytes(), 0666))
+
+	for _, s := range cfg.TestServices.Services(sr) {
+		core.Explain(sink, "testing/"+s.Script, "the %s test service is needed because go.mod requires %s", s.Name, strings.Join(s.Modules, " or "))
+		script := must.Return(testServiceScripts.ReadFile(s.Script))
+		if s.Name == "k8s-envtest" {
+			script = bytes.ReplaceAll(script, []byte("ENVTEST_K8S_VERSION_DEFAULT"), []byte(envtestVersion(cfg)))
+		}
+		// the header goes below the shebang line
+		shebang, rest, _ := bytes.Cut(script, []byte("\n"))
+		script = []byte(fmt.Sprintf("%s\n%s\n%s", shebang, core.AutogeneratedHeader, rest))
+		must.Succeed(sink.WriteFile("testing/"+s.Script, script, 0666))
+	}
+}
 
-	if sr.UsesPostgres {
-		must.Succeed(os.MkdirAll("testing", os.ModePerm))
-		must.Succeed(os.WriteFile("testing/with-postgres-db.sh", withPostgresDBScript, 0666))
+func explainRule(sink core.OutputSink, r rule) {
+	subject := "Makefile:" + r.target
+	if len(r.reasons) == 0 {
+		core.Explain(sink, subject, "always generated")
+	}
+	for _, reason := range r.reasons {
+		core.Explain(sink, subject, reason)
 	}
 }
 
+// envtestVersion returns the version of the envtest binaries for tests,
+// which is the same as in the CI workflow.
+func envtestVersion(cfg *core.Configuration) string {
+	if cfg.GitHubWorkflow != nil && cfg.GitHubWorkflow.CI.KubernetesEnvtest.Version != "" {
+		return cfg.GitHubWorkflow.CI.KubernetesEnvtest.Version
+	}
+	return cfg.ToolVersions.Get("k8s-envtest")
+}
+
 // makefile holds the components of a Makefile.
 type makefile struct {
 	categories []category
@@ -209,6 +238,10 @@ type rule struct {
 	// See https://www.gnu.org/software/make/manual/make.html#Prerequisite-Types.
 	prerequisites          []string
 	orderOnlyPrerequisites []string
+
+	// reasons explain why this rule was generated (see core.Explain).
+	// If empty, the rule is generated unconditionally.
+	reasons []string
 }
 
 func (r *rule) addDefinition(def string, args ...any) {
@@ -0,0 +1,48 @@
+#!/bin/sh
+# shellcheck shell=ash
+set -euo pipefail
+
+# Darwin compatibility
+if hash greadlink >/dev/null 2>/dev/null; then
+  readlink() { greadlink "$@"; }
+fi
+
+# set working directory to repo root
+cd "$(dirname "$(dirname "$(readlink -f "$0")")")"
+
+step() {
+  printf '\x1B[1;36m>>\x1B[0;36m %s...\x1B[0m\n' "$1"
+}
+
+if [ ! -d testing/mariadb-data/ ]; then
+  step "First-time setup: Creating MariaDB database for testing"
+  mariadb-install-db --auth-root-authentication-method=normal --datadir="${PWD}/testing/mariadb-data" >/dev/null
+fi
+mkdir -p testing/mariadb-run/
+
+# usage in trap is not recognized
+# shellcheck disable=SC2317
+stop_mariadb() {
+  EXIT_CODE=$?
+  step "Stopping MariaDB"
+  kill "$(cat testing/mariadb-run/pid)"
+  while [ -f testing/mariadb-run/pid ]; do sleep 0.1; done
+  exit "${EXIT_CODE}"
+}
+
+step "Starting MariaDB"
+rm -f -- testing/mariadb.log
+trap stop_mariadb EXIT INT TERM
+mariadbd --no-defaults --datadir="${PWD}/testing/mariadb-data" \
+  --bind-address=127.0.0.1 --port=33061 \
+  --socket="${PWD}/testing/mariadb-run/socket" --pid-file="${PWD}/testing/mariadb-run/pid" \
+  --log-error="${PWD}/testing/mariadb.log" &
+until mariadb-admin --socket="${PWD}/testing/mariadb-run/socket" -u root ping >/dev/null 2>/dev/null; do sleep 0.1; done
+
+step "Running command: $*"
+set +e
+"$@"
+EXIT_CODE=$?
+set -e
+
+exit "${EXIT_CODE}"
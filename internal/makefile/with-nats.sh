@@ -0,0 +1,41 @@
+#!/bin/sh
+# shellcheck shell=ash
+set -euo pipefail
+
+# Darwin compatibility
+if hash greadlink >/dev/null 2>/dev/null; then
+  readlink() { greadlink "$@"; }
+fi
+
+# set working directory to repo root
+cd "$(dirname "$(dirname "$(readlink -f "$0")")")"
+
+step() {
+  printf '\x1B[1;36m>>\x1B[0;36m %s...\x1B[0m\n' "$1"
+}
+
+mkdir -p testing/nats-run/
+
+# usage in trap is not recognized
+# shellcheck disable=SC2317
+stop_nats() {
+  EXIT_CODE=$?
+  step "Stopping NATS"
+  kill "$(cat testing/nats-run/pid)"
+  exit "${EXIT_CODE}"
+}
+
+step "Starting NATS"
+rm -f -- testing/nats.log
+trap stop_nats EXIT INT TERM
+nats-server --addr 127.0.0.1 --port 42221 \
+  --pid "${PWD}/testing/nats-run/pid" --log "${PWD}/testing/nats.log" &
+until grep -q "Server is ready" testing/nats.log 2>/dev/null; do sleep 0.1; done
+
+step "Running command: $*"
+set +e
+"$@"
+EXIT_CODE=$?
+set -e
+
+exit "${EXIT_CODE}"
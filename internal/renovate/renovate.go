@@ -15,9 +15,9 @@
 package renovate
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/sapcc/go-bits/must"
@@ -45,7 +45,7 @@ func (c *config) addPackageRule(rule core.PackageRule) {
 	c.PackageRules = append(c.PackageRules, rule)
 }
 
-func RenderConfig(cfgRenovate core.RenovateConfig, scanResult core.ScanResult, url string, isApplicationRepo bool) {
+func RenderConfig(sink core.OutputSink, cfgRenovate core.RenovateConfig, scanResult core.ScanResult, url string, isApplicationRepo bool) {
 	isGoMakefileMakerRepo := scanResult.MustModulePath() == "github.com/sapcc/go-makefile-maker"
 	isInternalRenovate := strings.HasPrefix(url, "https://github.wdf.sap.corp")
 
@@ -68,6 +68,16 @@ func RenderConfig(cfgRenovate core.RenovateConfig, scanResult core.ScanResult, u
 		}
 	}
 
+	repoKind := "an application repo (it has binaries)"
+	if !isApplicationRepo {
+		repoKind = "a library repo (it has no binaries)"
+	}
+	renovateKind := "Mend's hosted Renovate"
+	if isInternalRenovate {
+		renovateKind = "the internal Renovate instance (metadata.url is on github.wdf.sap.corp)"
+	}
+	core.Explain(sink, ".github/renovate.json", "the schedule is %q because this is %s, which is served by %s", schedule, repoKind, renovateKind)
+
 	cfg := config{
 		Extends: []string{
 			"config:base",
@@ -152,13 +162,11 @@ func RenderConfig(cfgRenovate core.RenovateConfig, scanResult core.ScanResult, u
 		cfg.addPackageRule(rule)
 	}
 
-	must.Succeed(os.MkdirAll(".github", 0750))
-	f := must.Return(os.Create(".github/renovate.json"))
-
-	encoder := json.NewEncoder(f)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
 	encoder.SetEscapeHTML(false) // in order to preserve `<` in allowedVersions field
 	must.Succeed(encoder.Encode(cfg))
 
-	must.Succeed(f.Close())
+	must.Succeed(sink.WriteFile(".github/renovate.json", buf.Bytes(), 0666))
 }
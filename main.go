@@ -19,16 +19,20 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
 
-	"gopkg.in/yaml.v3"
-
 	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 
 	"github.com/sapcc/go-makefile-maker/internal/core"
+	"github.com/sapcc/go-makefile-maker/internal/diff"
 	"github.com/sapcc/go-makefile-maker/internal/dockerfile"
 	"github.com/sapcc/go-makefile-maker/internal/ghworkflow"
 	"github.com/sapcc/go-makefile-maker/internal/golangcilint"
@@ -38,47 +42,192 @@ import (
 )
 
 func main() {
-	file := must.Return(os.Open("Makefile.maker.yaml"))
+	checkMode := flag.Bool("check", false, "Do not write any files. Instead, show a diff for every generated file that is out of date and exit non-zero if there are any.")
+	force := flag.Bool("force", false, "Overwrite generated files even if they have been edited by hand or were not generated by go-makefile-maker.")
+	printConfig := flag.Bool("print-config", false, "Do not write any files. Instead, print the effective configuration after merging all files referenced via `extends`.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s [--check|--force]  generate files according to Makefile.maker.yaml\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s --print-config    print Makefile.maker.yaml after merging the files that it extends\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s explain [target|workflow|file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "                       show why the given output (or all outputs) is generated the way it is\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s init [--force]    create a Makefile.maker.yaml for the repository in the current directory\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s migrate [--dry-run] [file...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "                       rewrite obsolete keys in Makefile.maker.yaml (or the given files)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s pin-actions       resolve the GitHub actions used in workflows to commits for githubWorkflow.pinActions\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s schema            print the JSON schema for Makefile.maker.yaml\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	var cfg core.Configuration
-	dec := yaml.NewDecoder(file)
-	dec.KnownFields(true)
-	must.Succeed(dec.Decode(&cfg))
-	must.Succeed(file.Close())
-	cfg.Validate()
+	switch flag.Arg(0) {
+	case "":
+		if *printConfig {
+			printEffectiveConfig()
+		} else {
+			generate(*checkMode, *force)
+		}
+	case "explain":
+		runExplain(flag.Args()[1:])
+	case "init":
+		runInit(flag.Args()[1:])
+	case "migrate":
+		runMigrate(flag.Args()[1:])
+	case "pin-actions":
+		runPinActions(flag.Args()[1:])
+	case "schema":
+		printSchema(flag.Args()[1:])
+	default:
+		flag.Usage()
+		logg.Fatal("unknown subcommand: %q", flag.Arg(0))
+	}
+}
 
-	if cfg.GitHubWorkflow != nil && !strings.HasPrefix(cfg.Metadata.URL, "https://github.com") {
-		cfg.GitHubWorkflow.IsSelfHostedRunner = true
+// generate renders all files according to Makefile.maker.yaml.
+func generate(checkMode, force bool) {
+	// The generated files (and the updated go.mod) are collected in memory
+	// first. In check mode, they are compared with the files on disk
+	// afterwards. Otherwise, they are only written to disk if no hand-edited
+	// files would be overwritten.
+	memorySink := core.NewMemorySink()
+	conflicts := generateFiles(".", memorySink)
+
+	if checkMode {
+		// hand-edited files show up in the diff anyway
+		reportDrift(memorySink.Files())
+		return
 	}
 
-	if cfg.Golang.SetGoModVersion {
-		modFileBytes := must.Return(os.ReadFile(core.ModFilename))
-		rgx := regexp.MustCompile(`go \d\.\d\d`)
-		modFileBytesReplaced := rgx.ReplaceAll(modFileBytes, []byte("go "+core.DefaultGoVersion))
-		must.Succeed(os.WriteFile(core.ModFilename, modFileBytesReplaced, 0o666))
+	if len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			if force {
+				logg.Other("WARNING", "overwriting %s", conflict)
+			} else {
+				logg.Error("refusing to overwrite %s", conflict)
+			}
+		}
+		if !force {
+			logg.Fatal("no files were written; move your changes into %s (or into the `verbatim` section), or use --force to overwrite these files anyway", core.ConfigFilename)
+		}
 	}
+	must.Succeed(memorySink.ApplyTo(core.DiskSink{}))
+}
+
+// generateFiles renders all files for the repository in the given directory
+// into the given sink (with paths relative to that directory), including the
+// updated go.mod and manifest files. It returns the paths of all files that
+// must not be overwritten without --force.
+func generateFiles(root string, sink *core.MemorySink) (conflicts []string) {
+	cfg, sr := prepare(root, sink)
+
+	// All generated files are recorded in the manifest, so that we can remove
+	// them once they are not generated anymore.
+	manifestSink, err := core.NewManifestSink(sink, root)
+	if err != nil {
+		logg.Fatal(err.Error())
+	}
+	render(manifestSink, &cfg, sr)
+	warnings, conflicts, err := manifestSink.Finish()
+	must.Succeed(err)
+	for _, warning := range warnings {
+		logg.Other("WARNING", warning)
+	}
+	return conflicts
+}
+
+// prepare does everything that needs to happen before the files can be
+// rendered: It reads the configuration, scans the repository in the given
+// directory, and detects binaries if requested. If go.mod needs to be
+// updated, the new version is put into the given sink instead of onto disk,
+// and the scan result already reflects it.
+func prepare(root string, sink core.OutputSink) (core.Configuration, core.ScanResult) {
+	cfg := readConfig(filepath.Join(root, core.ConfigFilename))
 
 	// Scan go.mod file for additional context information.
-	sr := core.Scan()
+	sr := core.Scan(root, cfg.Golang.NestedModules)
+
+	if cfg.Golang.SetGoModVersion || cfg.Golang.Toolchain != "" {
+		goVersion := ""
+		if cfg.Golang.SetGoModVersion {
+			goVersion = cfg.ToolVersions.Get("go")
+		}
+		modFileBytes := must.Return(os.ReadFile(filepath.Join(root, core.ModFilename)))
+		newModFileBytes, err := core.UpdateGoMod(core.ModFilename, modFileBytes, goVersion, cfg.Golang.Toolchain)
+		if err != nil {
+			logg.Fatal("cannot update %s: %s", core.ModFilename, err.Error())
+		}
+		if !bytes.Equal(modFileBytes, newModFileBytes) {
+			must.Succeed(sink.WriteFile(core.ModFilename, newModFileBytes, 0o666))
+		}
+		// the new go.mod is not on disk yet, so Scan() could not see it
+		must.Succeed(sr.UpdateGoDirectives(newModFileBytes))
+	}
+
+	err := cfg.DetectBinaries(root, sr)
+	if err != nil {
+		logg.Fatal(err.Error())
+	}
+	return cfg, sr
+}
+
+// readConfig reads and validates the configuration file at the given path.
+// All validation errors and warnings are logged together, and the program
+// terminates if there were any errors.
+func readConfig(path string) core.Configuration {
+	cfg, issues := core.LoadConfiguration(path)
+	for _, issue := range issues {
+		if issue.IsWarning {
+			logg.Other("WARNING", issue.Format(path))
+		} else {
+			logg.Error(issue.Format(path))
+		}
+	}
+	if issues.HasErrors() {
+		logg.Fatal("%s is not valid, see above for details", path)
+	}
+
+	return cfg
+}
+
+// printEffectiveConfig prints the configuration after merging all base files
+// referenced via `extends`.
+func printEffectiveConfig() {
+	readConfig(core.ConfigFilename) // to report validation errors
+	buf := must.Return(core.EffectiveConfigYAML(core.ConfigFilename))
+	must.Return(os.Stdout.Write(buf))
+}
+
+// render generates all files that are enabled in the given configuration, and
+// puts them into the given sink.
+func render(sink core.OutputSink, cfg *core.Configuration, sr core.ScanResult) {
+	if cfg.GitHubWorkflow != nil && !strings.HasPrefix(cfg.Metadata.URL, "https://github.com") {
+		cfg.GitHubWorkflow.IsSelfHostedRunner = true
+	}
 
 	// Render Makefile
 	if cfg.Makefile.Enabled == nil || *cfg.Makefile.Enabled {
-		makefile.Render(&cfg, sr)
+		core.Explain(sink, "Makefile", "makefile.enabled is not set to false")
+		makefile.Render(sink, cfg, sr)
 	}
 
 	// Render Dockerfile
 	if cfg.Dockerfile.Enabled {
-		dockerfile.RenderConfig(cfg)
+		core.Explain(sink, "Dockerfile", "dockerfile.enabled is set")
+		core.Explain(sink, ".dockerignore", "dockerfile.enabled is set")
+		dockerfile.RenderConfig(sink, *cfg, sr)
 	}
 
 	// Render golangci-lint config file
 	if cfg.GolangciLint.CreateConfig {
-		golangcilint.RenderConfig(cfg.GolangciLint, cfg.Golang.EnableVendoring, sr.MustModulePath(), cfg.SpellCheck.IgnoreWords)
+		core.Explain(sink, ".golangci.yaml", "golangciLint.createConfig is set")
+		golangcilint.RenderConfig(sink, cfg.GolangciLint, cfg.Golang.EnableVendoring, sr.MustModulePath(), cfg.SpellCheck.IgnoreWords)
 	}
 
 	// Render Goreleaser config file
 	if cfg.GoReleaser.CreateConfig {
-		goreleaser.RenderConfig(cfg)
+		core.Explain(sink, ".goreleaser.yaml", "goReleaser.createConfig is set")
+		goreleaser.RenderConfig(sink, *cfg, sr)
 	}
 
 	// Render GitHub workflows
@@ -87,9 +236,9 @@ func main() {
 			if sr.GoVersion == "" {
 				logg.Fatal("could not find Go version from go.mod file, consider defining manually by setting 'githubWorkflow.global.goVersion' in config")
 			}
-			cfg.GitHubWorkflow.Global.GoVersion = sr.GoVersion
+			cfg.GitHubWorkflow.Global.GoVersion = sr.GoToolchainVersion()
 		}
-		ghworkflow.Render(&cfg)
+		ghworkflow.Render(sink, cfg, sr)
 	}
 
 	// Render Renovate config
@@ -101,6 +250,46 @@ func main() {
 			cfg.Renovate.GoVersion = sr.GoVersion
 		}
 		isApplicationRepo := len(cfg.Binaries) > 0
-		renovate.RenderConfig(cfg.Renovate, sr, cfg.Metadata.URL, isApplicationRepo)
+		core.Explain(sink, ".github/renovate.json", "renovate.enabled is set")
+		renovate.RenderConfig(sink, cfg.Renovate, sr, cfg.Metadata.URL, isApplicationRepo)
+	}
+}
+
+// reportDrift compares the outputs recorded in check mode with the files on
+// disk, prints a diff for each file that is out of date, and exits non-zero
+// if there are any.
+func reportDrift(outputs []core.PendingFile) {
+	outdatedCount := 0
+	for _, f := range outputs {
+		onDisk, err := os.ReadFile(f.Path)
+		exists := true
+		if errors.Is(err, fs.ErrNotExist) {
+			exists = false
+		} else {
+			must.Succeed(err)
+		}
+
+		oldName, newName := "a/"+f.Path, "b/"+f.Path
+		if !exists {
+			oldName = "/dev/null"
+		}
+		if f.Deleted {
+			newName = "/dev/null"
+		}
+		d := diff.Unified(oldName, newName, string(onDisk), string(f.Contents))
+		if d == "" && exists != f.Deleted {
+			continue
+		}
+		if d == "" {
+			// can only happen for empty files being created or deleted
+			d = fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName)
+		}
+
+		fmt.Print(d)
+		outdatedCount++
+	}
+
+	if outdatedCount > 0 {
+		logg.Fatal("%d generated file(s) are out of date, run go-makefile-maker to update them", outdatedCount)
 	}
 }
@@ -0,0 +1,166 @@
+/******************************************************************************
+*
+*  Copyright 2023 SAP SE
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+*
+******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/sapcc/go-makefile-maker/internal/core"
+	"github.com/sapcc/go-makefile-maker/internal/diff"
+)
+
+var updateGoldenFiles = flag.Bool("update", false, "overwrite the expected outputs in testdata/ with the actual outputs")
+
+// TestGoldenFiles runs the generator on each fixture in testdata/ and
+// compares the outputs (including the manifest and, if it is updated, go.mod)
+// against the files in its "expected" subdirectory.
+// Each fixture directory contains a Makefile.maker.yaml and a go.mod file.
+//
+// When the generator output changes intentionally, run `go test . -update` to
+// regenerate the expected outputs, and review the changes with `git diff`.
+func TestGoldenFiles(t *testing.T) {
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		fixtureDir := filepath.Join("testdata", entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			// same code path as in generate(), but without touching the disk
+			sink := core.NewMemorySink()
+			conflicts := generateFiles(fixtureDir, sink)
+			for _, conflict := range conflicts {
+				t.Errorf("unexpected conflict: %s", conflict)
+			}
+
+			expectedDir := filepath.Join(fixtureDir, "expected")
+			if *updateGoldenFiles {
+				writeGoldenFiles(t, expectedDir, sink)
+				return
+			}
+			compareGoldenFiles(t, expectedDir, sink)
+		})
+	}
+}
+
+// TestSchemaIsUpToDate checks that the committed JSON schema matches the
+// output of `go-makefile-maker schema`.
+func TestSchemaIsUpToDate(t *testing.T) {
+	actual := string(renderSchema())
+	if *updateGoldenFiles {
+		err := os.WriteFile(schemaFilename, []byte(actual), 0o666)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return
+	}
+
+	buf, err := os.ReadFile(schemaFilename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if d := diff.Unified("a/"+schemaFilename, "b/"+schemaFilename, string(buf), actual); d != "" {
+		t.Errorf("%s is out of date (run `go test . -update` to regenerate it):\n%s", schemaFilename, d)
+	}
+}
+
+func writeGoldenFiles(t *testing.T, expectedDir string, sink *core.MemorySink) {
+	t.Helper()
+	err := os.RemoveAll(expectedDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	diskSink := core.DiskSink{Root: expectedDir}
+	for _, f := range sink.Files() {
+		if f.Deleted {
+			continue
+		}
+		err := diskSink.WriteFile(f.Path, f.Contents, 0o666)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func compareGoldenFiles(t *testing.T, expectedDir string, sink *core.MemorySink) {
+	t.Helper()
+
+	// collect the expected outputs
+	expected := make(map[string]string)
+	err := filepath.WalkDir(expectedDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(expectedDir, path)
+		if err != nil {
+			return err
+		}
+		expected[filepath.ToSlash(relPath)] = string(buf)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// collect the actual outputs
+	actual := make(map[string]string)
+	for _, f := range sink.Files() {
+		if !f.Deleted {
+			actual[f.Path] = string(f.Contents)
+		}
+	}
+
+	var paths []string
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	for path := range actual {
+		if _, exists := expected[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		expectedContents, isExpected := expected[path]
+		actualContents, isActual := actual[path]
+		switch {
+		case !isExpected:
+			t.Errorf("unexpected output file %s (run `go test . -update` if this is intentional)", path)
+		case !isActual:
+			t.Errorf("missing output file %s (run `go test . -update` if this is intentional)", path)
+		case expectedContents != actualContents:
+			t.Errorf("output file %s does not match (run `go test . -update` if this is intentional):\n%s",
+				path, diff.Unified("expected/"+path, "actual/"+path, expectedContents, actualContents))
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/sapcc/example-app
+
+go 1.21
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/sapcc/go-api-declarations v1.10.7
+	github.com/sapcc/go-bits v0.0.0-20231106151414-f5f780233936
+	k8s.io/client-go v0.28.4
+)
+
+require golang.org/x/sys v0.14.0 // indirect
@@ -0,0 +1,5 @@
+module github.com/example/foo/v2
+
+go 1.21
+
+toolchain go1.21.5
@@ -0,0 +1,5 @@
+package sqlite
+
+// #cgo LDFLAGS: -lsqlite3
+// #include <sqlite3.h>
+import "C"
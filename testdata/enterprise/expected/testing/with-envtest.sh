@@ -0,0 +1,32 @@
+#!/bin/sh
+################################################################################
+# This file is AUTOGENERATED with <https://github.com/sapcc/go-makefile-maker> #
+# Edit Makefile.maker.yaml instead.                                            #
+################################################################################
+# shellcheck shell=ash
+set -euo pipefail
+
+# Darwin compatibility
+if hash greadlink >/dev/null 2>/dev/null; then
+  readlink() { greadlink "$@"; }
+fi
+
+# set working directory to repo root
+cd "$(dirname "$(dirname "$(readlink -f "$0")")")"
+
+step() {
+  printf '\x1B[1;36m>>\x1B[0;36m %s...\x1B[0m\n' "$1"
+}
+
+if hash setup-envtest 2>/dev/null; then
+  setup_envtest() { setup-envtest "$@"; }
+else
+  setup_envtest() { go run sigs.k8s.io/controller-runtime/tools/setup-envtest@latest "$@"; }
+fi
+
+step "Installing envtest binaries"
+KUBEBUILDER_ASSETS="$(setup_envtest use --bin-dir "${PWD}/testing/envtest-bin" -p path "${ENVTEST_K8S_VERSION:-1.26.x!}")"
+export KUBEBUILDER_ASSETS
+
+step "Running command: $*"
+exec "$@"
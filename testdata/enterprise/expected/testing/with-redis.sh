@@ -0,0 +1,46 @@
+#!/bin/sh
+################################################################################
+# This file is AUTOGENERATED with <https://github.com/sapcc/go-makefile-maker> #
+# Edit Makefile.maker.yaml instead.                                            #
+################################################################################
+# shellcheck shell=ash
+set -euo pipefail
+
+# Darwin compatibility
+if hash greadlink >/dev/null 2>/dev/null; then
+  readlink() { greadlink "$@"; }
+fi
+
+# set working directory to repo root
+cd "$(dirname "$(dirname "$(readlink -f "$0")")")"
+
+step() {
+  printf '\x1B[1;36m>>\x1B[0;36m %s...\x1B[0m\n' "$1"
+}
+
+mkdir -p testing/redis-run/
+
+# usage in trap is not recognized
+# shellcheck disable=SC2317
+stop_redis() {
+  EXIT_CODE=$?
+  step "Stopping Redis"
+  redis-cli -p 63791 shutdown nosave >/dev/null
+  exit "${EXIT_CODE}"
+}
+
+step "Starting Redis"
+rm -f -- testing/redis.log
+trap stop_redis EXIT INT TERM
+redis-server --bind 127.0.0.1 --port 63791 --dir "${PWD}/testing/redis-run" \
+  --save '' --appendonly no --daemonize yes \
+  --pidfile "${PWD}/testing/redis-run/pid" --logfile "${PWD}/testing/redis.log"
+until redis-cli -p 63791 ping >/dev/null 2>/dev/null; do sleep 0.1; done
+
+step "Running command: $*"
+set +e
+"$@"
+EXIT_CODE=$?
+set -e
+
+exit "${EXIT_CODE}"
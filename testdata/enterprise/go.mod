@@ -0,0 +1,12 @@
+module github.wdf.sap.corp/example/service
+
+go 1.21
+
+require (
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/jackc/pgx/v5 v5.5.0
+	github.com/nats-io/nats.go v1.31.0
+	github.com/redis/go-redis/v9 v9.3.0
+	github.com/sapcc/go-api-declarations v1.1.0
+	sigs.k8s.io/controller-runtime v0.16.3
+)
@@ -0,0 +1,8 @@
+package parser
+
+import "strings"
+
+// Parse splits a comma-separated list.
+func Parse(input string) []string {
+	return strings.Split(input, ",")
+}
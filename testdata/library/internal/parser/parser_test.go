@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	if len(Parse("a,b")) != 2 {
+		t.Error("expected two fields")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("a,b")
+	f.Fuzz(func(t *testing.T, input string) {
+		if strings.Join(Parse(input), ",") != input {
+			t.Errorf("roundtrip failed for %q", input)
+		}
+	})
+}
+
+func FuzzRoundtrip(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		Parse(input)
+	})
+}
+
+// not a fuzz test: "Fuzz" is followed by a lowercase letter
+func Fuzzy(f *testing.F) {}
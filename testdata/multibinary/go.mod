@@ -0,0 +1,5 @@
+module github.com/example/bar
+
+go 1.21
+
+require github.com/sapcc/go-api-declarations v1.10.7
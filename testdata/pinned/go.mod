@@ -0,0 +1,3 @@
+module github.com/example/pinned
+
+go 1.21
@@ -0,0 +1,3 @@
+module github.com/example/service/api
+
+go 1.22
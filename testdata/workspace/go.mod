@@ -0,0 +1,5 @@
+module github.com/example/service
+
+go 1.22
+
+require github.com/example/service/api v0.1.0